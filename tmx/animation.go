@@ -0,0 +1,132 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"time"
+)
+
+// A frame of a tile animation.
+type Frame struct {
+	TileID   ID  `xml:"tileid,attr" json:"tileid"`     // ID of the tile shown, in the same tileset as the animated tile.
+	Duration int `xml:"duration,attr" json:"duration"` // How long the frame is shown, in milliseconds.
+}
+
+// Returns the tile with the given ID, or nil if the tileset has no per-tile data for it.
+func (ts *Tileset) Tile(id ID) *Tile {
+	for i := 0; i < len(ts.Tiles); i++ {
+		if ts.Tiles[i].ID == id {
+			return &ts.Tiles[i]
+		}
+	}
+	return nil
+}
+
+// Returns the animation of the tile, or nil if it is not animated.
+func (t *DecodedTile) Animation() []Frame {
+	if t.Nil || t.Tileset == nil {
+		return nil
+	}
+
+	tile := t.Tileset.Tile(t.ID)
+	if tile == nil {
+		return nil
+	}
+	return tile.Animation
+}
+
+// Returns the ID of the tile shown elapsed time after the animation started, looping the animation.
+// Tiles without an animation always show themselves.
+func (t *Tile) FrameAt(elapsed time.Duration) ID {
+	return frameAt(t.Animation, animationLength(t.Animation), elapsed, t.ID)
+}
+
+func animationLength(frames []Frame) time.Duration {
+	var length time.Duration
+	for _, f := range frames {
+		length += time.Duration(f.Duration) * time.Millisecond
+	}
+	return length
+}
+
+func frameAt(frames []Frame, length, elapsed time.Duration, id ID) ID {
+	if len(frames) == 0 {
+		return id
+	}
+	if length <= 0 {
+		return frames[0].TileID
+	}
+
+	elapsed %= length
+	if elapsed < 0 {
+		elapsed += length
+	}
+
+	for _, f := range frames {
+		elapsed -= time.Duration(f.Duration) * time.Millisecond
+		if elapsed < 0 {
+			return f.TileID
+		}
+	}
+	return frames[len(frames)-1].TileID
+}
+
+// An Animator keeps track of all animated tiles of a tileset, so that the frames they show can be looked up at once.
+// All animations are assumed to have started at the same time.
+type Animator struct {
+	Tileset *Tileset
+
+	tiles   []*Tile
+	lengths []time.Duration
+	frames  map[ID]ID
+}
+
+func NewAnimator(ts *Tileset) *Animator {
+	a := &Animator{Tileset: ts, frames: make(map[ID]ID)}
+	for i := 0; i < len(ts.Tiles); i++ {
+		if t := &ts.Tiles[i]; len(t.Animation) > 0 {
+			a.tiles = append(a.tiles, t)
+			a.lengths = append(a.lengths, animationLength(t.Animation))
+		}
+	}
+	return a
+}
+
+// Returns, for each animated tile, the ID of the tile shown elapsed time after the animations started.
+// The returned map is reused by subsequent calls.
+func (a *Animator) Frames(elapsed time.Duration) map[ID]ID {
+	for i, t := range a.tiles {
+		a.frames[t.ID] = frameAt(t.Animation, a.lengths[i], elapsed, t.ID)
+	}
+	return a.frames
+}
+
+// Returns the ID of the tile to be drawn in place of id, elapsed time after the animations started.
+func (a *Animator) Frame(id ID, elapsed time.Duration) ID {
+	for i, t := range a.tiles {
+		if t.ID == id {
+			return frameAt(t.Animation, a.lengths[i], elapsed, id)
+		}
+	}
+	return id
+}
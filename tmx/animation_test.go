@@ -0,0 +1,104 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readTestTileset(t *testing.T, name string) *Tileset {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ts, err := ReadTileset(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestAnimation(t *testing.T) {
+	const tmx = `<map width="2" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" source="testdata/tilesets/animated.tsx"/>
+ <layer name="L" width="2" height="1"><data encoding="csv">7,1</data></layer>
+</map>`
+
+	m, err := Read(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if frames := m.Layers[0].TileAt(0, 0).Animation(); len(frames) != 3 || frames[2].TileID != 20 || frames[2].Duration != 200 {
+		t.Error("Wrong animation", frames)
+	}
+	if frames := m.Layers[0].TileAt(1, 0).Animation(); frames != nil {
+		t.Error("Tile without animation has frames", frames)
+	}
+	if frames := NilTile.Animation(); frames != nil {
+		t.Error("NilTile has frames", frames)
+	}
+}
+
+func TestAnimator(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/animated.tsx")
+	a := NewAnimator(ts)
+
+	steps := []struct {
+		elapsed time.Duration
+		frames  map[ID]ID
+	}{
+		{0, map[ID]ID{6: 6, 21: 21}},
+		{150 * time.Millisecond, map[ID]ID{6: 7, 21: 21}},
+		{300 * time.Millisecond, map[ID]ID{6: 20, 21: 22}},
+		{400 * time.Millisecond, map[ID]ID{6: 6, 21: 22}},
+		{550 * time.Millisecond, map[ID]ID{6: 7, 21: 21}},
+	}
+
+	for _, step := range steps {
+		frames := a.Frames(step.elapsed)
+		if len(frames) != len(step.frames) {
+			t.Error("Wrong number of animated tiles", frames)
+		}
+		for id, frame := range step.frames {
+			if frames[id] != frame {
+				t.Error("Wrong frame for tile", id, "at", step.elapsed, ":", frames[id])
+			}
+			if a.Frame(id, step.elapsed) != frame {
+				t.Error("Frame disagrees with Frames for tile", id, "at", step.elapsed)
+			}
+		}
+	}
+
+	if a.Frame(0, time.Second) != 0 {
+		t.Error("Tile without animation changed")
+	}
+	if ts.Tile(6).FrameAt(-50*time.Millisecond) != 20 {
+		t.Error("Wrong frame for negative elapsed time")
+	}
+}
@@ -0,0 +1,279 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"image"
+	"math/rand"
+)
+
+// The colors painted on the corners and edges of the cells of a Width x Height area, from which an Autotiler
+// picks tiles. Colors are kept at the points of a grid of 2*Width+1 x 2*Height+1 points, the top-left corner of
+// cell (x, y) being point (2x, 2y), its top edge (2x+1, 2y) and its center (2x+1, 2y+1), so that neighbouring
+// cells share their corners and edges. Color 0 is no color, as in WangID.
+type WangGrid struct {
+	Width, Height int
+
+	points []uint8
+}
+
+func NewWangGrid(width, height int) *WangGrid {
+	return &WangGrid{Width: width, Height: height, points: make([]uint8, (2*width+1)*(2*height+1))}
+}
+
+// Returns the color at point (x, y) of the grid, or 0 outside of it.
+func (g *WangGrid) At(x, y int) uint8 {
+	if x < 0 || y < 0 || x > 2*g.Width || y > 2*g.Height {
+		return 0
+	}
+	return g.points[y*(2*g.Width+1)+x]
+}
+
+// Sets the color at point (x, y) of the grid; points outside of it are ignored.
+func (g *WangGrid) Set(x, y int, c uint8) {
+	if x < 0 || y < 0 || x > 2*g.Width || y > 2*g.Height {
+		return
+	}
+	g.points[y*(2*g.Width+1)+x] = c
+}
+
+// Sets the color of the corner at the top-left of cell (x, y), shared with the cells to its left and top.
+func (g *WangGrid) SetCorner(x, y int, c uint8) {
+	g.Set(2*x, 2*y, c)
+}
+
+// Sets the colors of all corners, edges and centers of the cells in r.
+func (g *WangGrid) Fill(r image.Rectangle, c uint8) {
+	for y := 2 * r.Min.Y; y <= 2*r.Max.Y; y++ {
+		for x := 2 * r.Min.X; x <= 2*r.Max.X; x++ {
+			g.Set(x, y, c)
+		}
+	}
+}
+
+// Offsets of the points of the corners and edges of a cell from its center, in the order of WangID.
+var wangPoints = [len(WangID{})]image.Point{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+
+// Returns the colors of the corners and edges of cell (x, y).
+func (g *WangGrid) WangID(x, y int) WangID {
+	var id WangID
+	for i, p := range wangPoints {
+		id[i] = g.At(2*x+1+p.X, 2*y+1+p.Y)
+	}
+	return id
+}
+
+// Picks tiles of a wang set for the cells of a layer from the colors of a WangGrid, the way Tiled's terrain
+// tools do. Tiles are flipped and rotated when the Transformations of the tileset allow it.
+// Cell (x, y) of the grid is cell (x, y) of the layer: the grid starts at (0, 0), so cells at negative
+// coordinates of infinite maps cannot be autotiled.
+type Autotiler struct {
+	Layer   *Layer
+	Tileset *Tileset // The tileset of WangSet, which must have its FirstGID in the map of Layer.
+	WangSet *WangSet
+	Rand    *rand.Rand // Source of the random choice among matching tiles; the default source of math/rand if nil.
+
+	variants []wangVariant
+}
+
+// A tile of the wang set, as it is drawn with a combination of flips.
+type wangVariant struct {
+	id          WangID
+	gid         GID // Flip bits included.
+	probability float64
+	transformed bool
+}
+
+func NewAutotiler(l *Layer, ts *Tileset, ws *WangSet) *Autotiler {
+	a := &Autotiler{Layer: l, Tileset: ts, WangSet: ws}
+	a.variants = wangVariants(ts, ws)
+	return a
+}
+
+// Returns the wang ID of a tile drawn with the given flips, which are applied in the order of GIDs: the diagonal
+// flip first, then the horizontal and vertical ones.
+func flipWangID(id WangID, diagonal, horizontal, vertical bool) WangID {
+	flip := func(id WangID, to func(int) int) WangID {
+		var flipped WangID
+		for i, c := range id {
+			flipped[to(i)] = c
+		}
+		return flipped
+	}
+	if diagonal {
+		id = flip(id, func(i int) int { return (14 - i) % 8 })
+	}
+	if horizontal {
+		id = flip(id, func(i int) int { return (8 - i) % 8 })
+	}
+	if vertical {
+		id = flip(id, func(i int) int { return (12 - i) % 8 })
+	}
+	return id
+}
+
+// Returns all tiles of the wang set with the combinations of flips allowed by the tileset. Rotations are
+// combinations of the diagonal flip with the others; along with either flip, they allow all eight.
+func wangVariants(ts *Tileset, ws *WangSet) []wangVariant {
+	tr := ts.Transformations
+	allowed := func(d, h, v bool) bool {
+		switch {
+		case tr.Rotate && (tr.HFlip || tr.VFlip):
+			return true
+		case tr.Rotate:
+			return d == (h != v) // 0, 90 (DH), 180 (HV) and 270 degrees (DV).
+		}
+		return !d && (!h || tr.HFlip) && (!v || tr.VFlip)
+	}
+
+	var variants []wangVariant
+	for _, t := range ws.Tiles {
+		probability := 1.0
+		if tile := ts.Tile(t.TileID); tile != nil {
+			probability = tile.Probability
+		}
+		for _, c := range t.WangID {
+			if color := ws.Color(c); color != nil {
+				probability *= color.Probability
+			}
+		}
+
+		first := len(variants)
+		for flags := 0; flags < 8; flags++ {
+			d, h, v := flags&4 != 0, flags&2 != 0, flags&1 != 0
+			if !allowed(d, h, v) {
+				continue
+			}
+
+			id := flipWangID(t.WangID, d, h, v)
+			if containsWangID(variants[first:], id) {
+				continue // Symmetric tiles look the same with some flips.
+			}
+
+			gid := ts.FirstGID + GID(t.TileID)
+			if d {
+				gid |= GIDDiagonalFlip
+			}
+			if h {
+				gid |= GIDHorizontalFlip
+			}
+			if v {
+				gid |= GIDVerticalFlip
+			}
+			variants = append(variants, wangVariant{id, gid, probability, flags != 0})
+		}
+	}
+	return variants
+}
+
+func containsWangID(variants []wangVariant, id WangID) bool {
+	for _, v := range variants {
+		if v.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the pattern tiles of cell (x, y) have to match, and false if the cell has no colors at all.
+// Corners of edge sets and edges of corner sets match any color.
+func (a *Autotiler) pattern(g *WangGrid, x, y int) (WangID, bool) {
+	pattern, colored := g.WangID(x, y), false
+	for i, c := range pattern {
+		corner := i%2 == 1
+		if a.WangSet.Type == "corner" && !corner || a.WangSet.Type == "edge" && corner {
+			pattern[i] = WangAny
+			continue
+		}
+		colored = colored || c != 0
+	}
+	return pattern, colored
+}
+
+// Returns the GID of a tile matching pattern, and false if there is none. Tiles are picked at random, by their
+// Probability times that of each of their colors, so that tiles and colors with a zero Probability are never
+// picked. Untransformed tiles are picked over transformed ones if the tileset prefers them.
+func (a *Autotiler) pick(pattern WangID) (GID, bool) {
+	var total float64
+	var candidates []*wangVariant
+	for i := range a.variants {
+		v := &a.variants[i]
+		if v.probability <= 0 || !v.id.Matches(pattern) {
+			continue
+		}
+		if a.Tileset.Transformations.PreferUntransformed && len(candidates) > 0 && candidates[0].transformed != v.transformed {
+			if v.transformed {
+				continue
+			}
+			candidates, total = candidates[:0], 0
+		}
+		candidates = append(candidates, v)
+		total += v.probability
+	}
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	var r float64
+	if a.Rand != nil {
+		r = a.Rand.Float64() * total
+	} else {
+		r = rand.Float64() * total
+	}
+	for _, v := range candidates {
+		if r -= v.probability; r < 0 {
+			return v.gid, true
+		}
+	}
+	return candidates[len(candidates)-1].gid, true
+}
+
+// Picks tiles for the cells of the layer in r, as far as g has them, from the colors of g: tiles whose corners
+// and edges, as far as the type of the wang set uses them, have the same colors, including no color. Cells
+// without colors are cleared. Returns the number of cells left as they are: those no tile matched, and those
+// the layer cannot hold, such as cells outside of all chunks of an infinite map; see Layer.SetGIDAt.
+func (a *Autotiler) Apply(g *WangGrid, r image.Rectangle) (unmatched int) {
+	r = r.Intersect(image.Rect(0, 0, g.Width, g.Height))
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			pattern, colored := a.pattern(g, x, y)
+			if !colored {
+				a.Layer.SetGIDAt(x, y, 0)
+				continue
+			}
+
+			gid, ok := a.pick(pattern)
+			if !ok || !a.Layer.SetGIDAt(x, y, gid) {
+				unmatched++
+			}
+		}
+	}
+	return unmatched
+}
+
+// Paints the cells in r with color c, and picks tiles for them and the cells around them, whose corners and
+// edges they share. Returns the number of cells left as they are, as Apply.
+func (a *Autotiler) Paint(g *WangGrid, r image.Rectangle, c uint8) (unmatched int) {
+	g.Fill(r, c)
+	return a.Apply(g, r.Inset(-1))
+}
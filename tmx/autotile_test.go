@@ -0,0 +1,243 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestFlipWangID(t *testing.T) {
+	id := WangID{1, 2, 3, 4, 5, 6, 7, 8}
+	tests := []struct {
+		d, h, v bool
+		want    WangID
+	}{
+		{false, false, false, id},
+		{false, true, false, WangID{1, 8, 7, 6, 5, 4, 3, 2}},
+		{false, false, true, WangID{5, 4, 3, 2, 1, 8, 7, 6}},
+		{true, false, false, WangID{7, 6, 5, 4, 3, 2, 1, 8}},
+		{true, true, false, WangID{7, 8, 1, 2, 3, 4, 5, 6}}, // Rotated clockwise.
+		{false, true, true, WangID{5, 6, 7, 8, 1, 2, 3, 4}}, // Rotated by 180 degrees.
+	}
+	for _, test := range tests {
+		if got := flipWangID(id, test.d, test.h, test.v); got != test.want {
+			t.Error(test.d, test.h, test.v, "wrong flipped wang ID", got)
+		}
+	}
+
+	rotated := id
+	for i := 0; i < 4; i++ {
+		rotated = flipWangID(rotated, true, true, false)
+	}
+	if rotated != id {
+		t.Error("Four rotations are not the identity", rotated)
+	}
+}
+
+func TestAutotilerCorners(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/wang.tsx")
+	ts.FirstGID = 1
+
+	l := &Layer{Width: 4, Height: 4}
+	g := NewWangGrid(4, 4)
+	a := NewAutotiler(l, ts, ts.WangSet("Ground"))
+	if n := a.Paint(g, image.Rect(0, 0, 4, 4), 1); n != 0 {
+		t.Fatal("Cells not matched", n)
+	}
+	if n := a.Paint(g, image.Rect(1, 1, 2, 2), 2); n != 0 {
+		t.Fatal("Cells not matched", n)
+	}
+
+	want := []GID{
+		5 | GIDHorizontalFlip | GIDVerticalFlip, 3 | GIDVerticalFlip, 5 | GIDVerticalFlip, 1,
+		4 | GIDHorizontalFlip, 2, 4, 1,
+		5 | GIDHorizontalFlip, 3, 5, 1,
+		1, 1, 1, 1,
+	}
+	if !equalGIDs(l.GIDs, want) {
+		t.Errorf("Wrong GIDs %x", l.GIDs)
+	}
+
+	// Without flips, only the tile with a sand corner at its top left matches.
+	ts.Transformations = Transformations{}
+	l, g = &Layer{Width: 2, Height: 2}, NewWangGrid(2, 2)
+	a = NewAutotiler(l, ts, ts.WangSet("Ground"))
+	a.Paint(g, image.Rect(0, 0, 2, 2), 1)
+	g.SetCorner(1, 1, 2)
+	if n := a.Apply(g, image.Rect(0, 0, 2, 2)); n != 3 || !equalGIDs(l.GIDs, []GID{1, 1, 1, 5}) {
+		t.Errorf("Wrong GIDs %x or unmatched cells %d", l.GIDs, n)
+	}
+
+	// Rotations alone give the other corners too.
+	ts.Transformations = Transformations{Rotate: true}
+	a = NewAutotiler(l, ts, ts.WangSet("Ground"))
+	if n := a.Apply(g, image.Rect(0, 0, 2, 2)); n != 0 ||
+		!equalGIDs(l.GIDs, []GID{5 | GIDHorizontalFlip | GIDVerticalFlip, 5 | GIDDiagonalFlip | GIDVerticalFlip, 5 | GIDDiagonalFlip | GIDHorizontalFlip, 5}) {
+		t.Errorf("Wrong GIDs %x or unmatched cells %d", l.GIDs, n)
+	}
+}
+
+func TestAutotilerEdges(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/wang.tsx")
+	ts.FirstGID = 1
+
+	l := &Layer{Width: 4, Height: 3, GIDs: []GID{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}}
+	g := NewWangGrid(4, 3)
+	g.Set(2, 3, 1) // Left edge of cell (1, 1).
+	g.Set(4, 3, 1) // Right edge of cell (1, 1), left of (2, 1).
+
+	a := NewAutotiler(l, ts, ts.WangSet("Roads"))
+	if n := a.Apply(g, image.Rect(0, 0, 4, 3)); n != 2 {
+		t.Error("Wrong number of unmatched cells", n)
+	}
+	want := []GID{0, 0, 0, 0, 1, 15, 1, 0, 0, 0, 0, 0}
+	if !equalGIDs(l.GIDs, want) {
+		t.Errorf("Wrong GIDs %x", l.GIDs)
+	}
+}
+
+func TestAutotilerProbability(t *testing.T) {
+	all := WangID{0, 1, 0, 1, 0, 1, 0, 1}
+	ts := &Tileset{
+		FirstGID:        1,
+		Transformations: Transformations{HFlip: true, PreferUntransformed: true},
+		Tiles:           []Tile{{ID: 0, Probability: 1}, {ID: 1, Probability: 3}, {ID: 2, Probability: 0}, {ID: 3, Probability: 100}},
+		WangSets: []WangSet{{
+			Type:   "corner",
+			Colors: []WangColor{{Name: "Grass", Probability: 1}},
+			Tiles:  []WangTile{{0, all}, {1, all}, {2, all}, {3, WangID{0, 0, 0, 1, 0, 1, 0, 1}}},
+		}},
+	}
+
+	l := &Layer{Width: 1, Height: 1}
+	g := NewWangGrid(1, 1)
+	g.Fill(image.Rect(0, 0, 1, 1), 1)
+	a := NewAutotiler(l, ts, &ts.WangSets[0])
+	a.Rand = rand.New(rand.NewSource(1))
+
+	counts := make(map[GID]int)
+	for i := 0; i < 4000; i++ {
+		a.Apply(g, image.Rect(0, 0, 1, 1))
+		counts[l.GIDs[0]]++
+	}
+	if counts[1]+counts[2] != 4000 || counts[1] < 850 || counts[1] > 1150 {
+		t.Error("Wrong distribution of tiles", counts)
+	}
+
+	// Flipped, the fourth tile is the only one with no color at its top left corner.
+	g.SetCorner(0, 0, 0)
+	if a.Apply(g, image.Rect(0, 0, 1, 1)); l.GIDs[0] != 4|GIDHorizontalFlip {
+		t.Errorf("Wrong GID %x", l.GIDs[0])
+	}
+
+	// A fifth tile matches with no color at the top right corner, as does the flipped fourth one, which is far more
+	// probable but only picked if untransformed tiles are not preferred.
+	g.SetCorner(0, 0, 1)
+	g.SetCorner(1, 0, 0)
+	ts.WangSets[0].Tiles = append(ts.WangSets[0].Tiles, WangTile{4, WangID{0, 0, 0, 1, 0, 1, 0, 1}})
+	ts.WangSets[0].Tiles[3].WangID = WangID{0, 1, 0, 1, 0, 1, 0, 0}
+	for _, prefer := range []bool{true, false} {
+		ts.Transformations.PreferUntransformed = prefer
+		a = NewAutotiler(l, ts, &ts.WangSets[0])
+		flipped := 0
+		for i := 0; i < 100; i++ {
+			a.Apply(g, image.Rect(0, 0, 1, 1))
+			if l.GIDs[0] == 4|GIDHorizontalFlip {
+				flipped++
+			}
+		}
+		if prefer && flipped != 0 || !prefer && flipped < 50 {
+			t.Error("Wrong number of flipped tiles", prefer, flipped)
+		}
+	}
+}
+
+func TestAutotilerUnheld(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/wang.tsx")
+	ts.FirstGID = 1
+
+	// Cells the layer cannot hold are left as they are, and counted.
+	for _, test := range []struct {
+		l    *Layer
+		want int
+	}{
+		{&Layer{}, 9},
+		{&Layer{Width: 2, Height: 2}, 5},
+		{&Layer{Data: Data{Chunks: []Chunk{{X: 1, Y: 1, Width: 2, Height: 2, GIDs: make([]GID, 4)}}}}, 5},
+	} {
+		g := NewWangGrid(3, 3)
+		a := NewAutotiler(test.l, ts, ts.WangSet("Ground"))
+		if n := a.Paint(g, image.Rect(0, 0, 3, 3), 1); n != test.want {
+			t.Error("Wrong number of cells left", n)
+		}
+	}
+}
+
+func TestSetGIDAt(t *testing.T) {
+	m, err := ReadFile("testdata/infinite.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &m.Layers[0]
+	if !l.SetGIDAt(-4, -4, 2) || l.GIDAt(-4, -4) != 2 {
+		t.Error("GID not set in a chunk")
+	}
+	if l.SetGIDAt(100, 100, 2) {
+		t.Error("GID set outside of all chunks")
+	}
+}
+
+func TestGIDAtChunks(t *testing.T) {
+	m, err := ReadFile("testdata/infinite.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &m.Layers[0]
+	check := func(what string) {
+		for _, c := range l.Data.Chunks {
+			for i, gid := range c.GIDs {
+				if x, y := c.X+i%c.Width, c.Y+i/c.Width; l.GIDAt(x, y) != gid {
+					t.Error(what, "wrong GID at", x, y)
+					return
+				}
+			}
+		}
+	}
+	check("indexed:")
+	if l.GIDAt(-5, 0) != 0 || l.GIDAt(8, 8) != 0 {
+		t.Error("GID found outside of all chunks")
+	}
+
+	l.Data.Chunks = append(l.Data.Chunks, Chunk{X: 8, Y: 8, Width: 4, Height: 4, GIDs: make([]GID, 16)})
+	if !l.SetGIDAt(9, 10, 3) || l.GIDAt(9, 10) != 3 {
+		t.Error("GID not set in an appended chunk")
+	}
+	check("appended:")
+
+	l.Data.Chunks = append(l.Data.Chunks, Chunk{X: -10, Y: 1, Width: 3, Height: 2, GIDs: []GID{1, 2, 3, 4, 5, 6}})
+	check("off the grid:")
+}
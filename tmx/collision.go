@@ -0,0 +1,173 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+// A collision shape of a tile placed on the map, in map pixel space, with the flips of the tile applied.
+type CollisionShape struct {
+	Object *Object // The shape as defined in the tileset, relative to the tile.
+
+	// Bounding rectangle of the shape. For unrotated rectangles (and ellipses) this is the shape itself.
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+
+	Points []Point // Vertices of polygons and polylines, in map pixel space.
+}
+
+// Returns the collision shapes of the tile at cell (x, y) of a layer, as defined by the objectgroup
+// of the tile in its tileset. Returns nil when there is no tile, or the tile has no shapes.
+// Tiles are placed where Tiled draws them for the orientation of the map, moved by the offset of their tileset
+// and that of the layer; they are not scaled to the grid of the map, whatever the TileRenderSize of the tileset.
+func (m *Map) CollisionShapes(l *Layer, x, y int) ([]CollisionShape, error) {
+	t := l.TileAt(x, y)
+	if t.Nil || t.Tileset == nil {
+		return nil, nil
+	}
+
+	tile := t.Tileset.Tile(t.ID)
+	if tile == nil || tile.ObjectGroup == nil || len(tile.ObjectGroup.Objects) == 0 {
+		return nil, nil
+	}
+
+	w, h := t.Tileset.TileWidth, t.Tileset.TileHeight
+	if r := t.Tileset.TileRect(t.ID); !r.Empty() {
+		w, h = r.Dx(), r.Dy()
+	}
+
+	// Tiles are aligned to the bottom-left corner of their cell.
+	originX, originY, err := m.cellOrigin(x, y)
+	if err != nil {
+		return nil, err
+	}
+	offsetX, offsetY := l.EffectiveOffset()
+	originX += offsetX + float64(t.Tileset.TileOffset.X)
+	originY += offsetY + float64(t.Tileset.TileOffset.Y-h)
+
+	transform := func(px, py float64) (float64, float64) {
+		tw, th := float64(w), float64(h)
+		if t.DiagonalFlip {
+			px, py = py, px
+			tw, th = th, tw
+		}
+		if t.HorizontalFlip {
+			px = tw - px
+		}
+		if t.VerticalFlip {
+			py = th - py
+		}
+		return originX + px, originY + py
+	}
+
+	shapes := make([]CollisionShape, 0, len(tile.ObjectGroup.Objects))
+	for i := 0; i < len(tile.ObjectGroup.Objects); i++ {
+		o := &tile.ObjectGroup.Objects[i]
+		s := CollisionShape{Object: o}
+
+		points, err := o.Points()
+		if err != nil {
+			return nil, err
+		}
+
+		if points != nil {
+			s.Points = make([]Point, len(points))
+			for j, p := range points {
+				p = o.Absolute(p)
+				p.X, p.Y = transform(p.X, p.Y)
+				s.Points[j] = p
+				s.extend(p.X, p.Y, j == 0)
+			}
+		} else {
+			corners := []Point{{0, 0}, {o.Width, 0}, {o.Width, o.Height}, {0, o.Height}}
+			for j, p := range corners {
+				p = o.Absolute(p)
+				px, py := transform(p.X, p.Y)
+				s.extend(px, py, j == 0)
+			}
+		}
+
+		shapes = append(shapes, s)
+	}
+	return shapes, nil
+}
+
+// Returns the bottom-left corner of cell (x, y), in map pixel space, as Tiled's renderers place it for the
+// orientation of the map.
+func (m *Map) cellOrigin(x, y int) (float64, float64, error) {
+	tw, th := m.TileWidth, m.TileHeight
+
+	switch m.Orientation {
+	case "", "orthogonal":
+		return float64(x * tw), float64((y + 1) * th), nil
+
+	case "isometric":
+		// The top corner of cell (0, 0) is at the middle of the map's width.
+		return float64((x-y+m.Height-1)*tw) / 2, float64((x+y+2)*th) / 2, nil
+
+	case "staggered", "hexagonal":
+		// Staggered maps are hexagonal maps with sides of length 0.
+		tw, th = tw&^1, th&^1
+		side := 0
+		if m.Orientation == "hexagonal" {
+			side = m.HexSideLength
+		}
+		even := m.StaggerIndex == "even"
+
+		var px, py int
+		if m.StaggerAxis == "x" {
+			px, py = x*((tw-side)/2+side), y*th
+			if x&1 == 1 != even {
+				py += th / 2
+			}
+		} else {
+			px, py = x*tw, y*((th-side)/2+side)
+			if y&1 == 1 != even {
+				px += tw / 2
+			}
+		}
+		return float64(px), float64(py + th), nil
+	}
+	return 0, 0, UnknownOrientation
+}
+
+// Grows the bounding rectangle of the shape to contain (x, y), or resets it to that point.
+func (s *CollisionShape) extend(x, y float64, reset bool) {
+	if reset {
+		s.X, s.Y, s.Width, s.Height = x, y, 0, 0
+		return
+	}
+
+	if x < s.X {
+		s.Width += s.X - x
+		s.X = x
+	} else if x > s.X+s.Width {
+		s.Width = x - s.X
+	}
+
+	if y < s.Y {
+		s.Height += s.Y - y
+		s.Y = y
+	} else if y > s.Y+s.Height {
+		s.Height = y - s.Y
+	}
+}
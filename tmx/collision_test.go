@@ -0,0 +1,171 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCollisionShapes(t *testing.T) {
+	tmx := fmt.Sprintf(`<map width="5" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" source="testdata/tilesets/collision.tsx"/>
+ <layer name="L" width="5" height="1"><data encoding="csv">1,%d,%d,%d,2</data></layer>
+</map>`, 1|GIDHorizontalFlip, 1|GIDVerticalFlip, 1|GIDDiagonalFlip)
+
+	m, err := Read(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type rect struct{ x, y, w, h float64 }
+	cells := []struct {
+		rect    rect
+		polygon []Point
+	}{
+		{rect{0, 0, 4, 2}, []Point{{2, 2}, {6, 2}, {6, 6}}},
+		{rect{12, 0, 4, 2}, []Point{{14, 2}, {10, 2}, {10, 6}}},
+		{rect{16, 6, 4, 2}, []Point{{18, 6}, {22, 6}, {22, 2}}},
+		{rect{24, 0, 2, 4}, []Point{{26, 2}, {26, 6}, {30, 6}}},
+	}
+
+	l := &m.Layers[0]
+	for x, cell := range cells {
+		shapes, err := m.CollisionShapes(l, x, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shapes) != 2 {
+			t.Fatal("Wrong number of shapes at", x, len(shapes))
+		}
+
+		r := shapes[0]
+		if (rect{r.X, r.Y, r.Width, r.Height}) != cell.rect {
+			t.Error("Wrong rectangle at", x, r)
+		}
+
+		p := shapes[1]
+		if len(p.Points) != len(cell.polygon) {
+			t.Fatal("Wrong number of points at", x, p.Points)
+		}
+		for i := range p.Points {
+			if p.Points[i] != cell.polygon[i] {
+				t.Error("Wrong polygon at", x, p.Points)
+				break
+			}
+		}
+	}
+
+	if shapes, err := m.CollisionShapes(l, 4, 0); shapes != nil || err != nil {
+		t.Error("Tile without collision shapes has some", shapes, err)
+	}
+	if shapes, err := m.CollisionShapes(l, 5, 0); shapes != nil || err != nil {
+		t.Error("Cell outside of the layer has collision shapes", shapes, err)
+	}
+}
+
+func TestCollisionShapesOrientation(t *testing.T) {
+	tests := []struct {
+		attrs string
+		x, y  int
+		want  [4]float64
+	}{
+		{`orientation="isometric" width="3" height="3" tilewidth="8" tileheight="4"`, 1, 0, [4]float64{12, -2, 4, 2}},
+		{`orientation="staggered" staggeraxis="y" staggerindex="odd" width="3" height="3" tilewidth="8" tileheight="4"`, 1, 1, [4]float64{12, -2, 4, 2}},
+		{`orientation="staggered" staggeraxis="y" staggerindex="odd" width="3" height="3" tilewidth="8" tileheight="4"`, 1, 2, [4]float64{8, 0, 4, 2}},
+		{`orientation="hexagonal" hexsidelength="4" staggeraxis="x" staggerindex="even" width="3" height="3" tilewidth="8" tileheight="8"`, 2, 1, [4]float64{12, 12, 4, 2}},
+		{`orientation="hexagonal" hexsidelength="4" staggeraxis="x" staggerindex="even" width="3" height="3" tilewidth="8" tileheight="8"`, 1, 1, [4]float64{6, 8, 4, 2}},
+	}
+
+	for _, test := range tests {
+		m, err := Read(strings.NewReader(`<map ` + test.attrs + `><tileset firstgid="1" source="testdata/tilesets/collision.tsx"/>
+ <layer name="L" width="3" height="3"><data encoding="csv">1,1,1,1,1,1,1,1,1</data></layer></map>`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shapes, err := m.CollisionShapes(&m.Layers[0], test.x, test.y)
+		if err != nil || len(shapes) != 2 {
+			t.Fatal(test.attrs, "wrong shapes", shapes, err)
+		}
+		if r := shapes[0]; [4]float64{r.X, r.Y, r.Width, r.Height} != test.want {
+			t.Error(test.attrs, "wrong rectangle at", test.x, test.y, r)
+		}
+	}
+}
+
+func TestCollisionShapesOffset(t *testing.T) {
+	m, err := Read(strings.NewReader(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" source="testdata/tilesets/collision.tsx"/>
+ <group name="G" offsetx="1"><layer name="L" width="1" height="1" offsetx="3" offsety="5"><data encoding="csv">1</data></layer></group>
+</map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Tilesets[0].TileOffset = TileOffset{2, -1}
+
+	shapes, err := m.CollisionShapes(&m.Layers[0], 0, 0)
+	if err != nil || len(shapes) != 2 {
+		t.Fatal("Wrong shapes", shapes, err)
+	}
+	if r := shapes[0]; r.X != 6 || r.Y != 4 {
+		t.Error("Wrong rectangle", r)
+	}
+	if p := shapes[1].Points[0]; p != (Point{8, 6}) {
+		t.Error("Wrong polygon", shapes[1].Points)
+	}
+
+	m.Orientation = "octagonal"
+	if _, err := m.CollisionShapes(&m.Layers[0], 0, 0); err != UnknownOrientation {
+		t.Error("Wrong error for an unknown orientation", err)
+	}
+}
+
+func TestCollisionShapesSubRect(t *testing.T) {
+	// The tile is the 8x4 part of its image at (16, 8), and is flipped within that.
+	tmx := fmt.Sprintf(`<map width="3" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="collection" tilewidth="112" tileheight="16" columns="0">
+  <tile id="0" x="16" y="8" width="8" height="4">
+   <image source="tiles.png" width="112" height="16"/>
+   <objectgroup><object id="1" x="0" y="0" width="4" height="2"/></objectgroup>
+  </tile>
+ </tileset>
+ <layer name="L" width="3" height="1"><data encoding="csv">1,%d,%d</data></layer>
+</map>`, 1|GIDHorizontalFlip, 1|GIDVerticalFlip)
+
+	m, err := Read(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for x, want := range [][4]float64{{0, 4, 4, 2}, {12, 4, 4, 2}, {16, 6, 4, 2}} {
+		shapes, err := m.CollisionShapes(&m.Layers[0], x, 0)
+		if err != nil || len(shapes) != 1 {
+			t.Fatal("Wrong shapes at", x, shapes, err)
+		}
+		if r := shapes[0]; [4]float64{r.X, r.Y, r.Width, r.Height} != want {
+			t.Error("Wrong rectangle at", x, r)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"strconv"
+	"sync"
+)
+
+// Reads the data element of a layer without keeping its inner XML: only the text is stored, with white space left
+// out in the csv and base64 encodings, and tiles and chunks are read directly from their attributes.
+func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	*d = Data{}
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "encoding":
+			d.Encoding = a.Value
+		case "compression":
+			d.Compression = a.Value
+		}
+	}
+
+	compact := d.Encoding == "csv" || d.Encoding == "base64"
+	return decodePayload(dec, compact, &d.RawData, &d.DataTiles, func(start xml.StartElement) error {
+		if start.Name.Local != "chunk" {
+			return dec.Skip()
+		}
+
+		var c Chunk
+		for _, a := range start.Attr {
+			var err error
+			switch a.Name.Local {
+			case "x":
+				c.X, err = strconv.Atoi(a.Value)
+			case "y":
+				c.Y, err = strconv.Atoi(a.Value)
+			case "width":
+				c.Width, err = strconv.Atoi(a.Value)
+			case "height":
+				c.Height, err = strconv.Atoi(a.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if err := decodePayload(dec, compact, &c.RawData, &c.DataTiles, nil); err != nil {
+			return err
+		}
+		d.Chunks = append(d.Chunks, c)
+		return nil
+	})
+}
+
+// Reads the content of a data or chunk element up to its end: text into raw, without white space if compact,
+// and tile elements into tiles. Other elements are handed to child, or skipped if it is nil.
+func decodePayload(dec *xml.Decoder, compact bool, raw *[]byte, tiles *[]DataTile, child func(xml.StartElement) error) error {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.CharData:
+			if !compact {
+				*raw = append(*raw, t...)
+				continue
+			}
+			for _, b := range t {
+				if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+					*raw = append(*raw, b)
+				}
+			}
+		case xml.StartElement:
+			if t.Name.Local != "tile" {
+				if child == nil {
+					err = dec.Skip()
+				} else {
+					err = child(t)
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			var tile DataTile
+			for _, a := range t.Attr {
+				if a.Name.Local == "gid" {
+					gid, err := strconv.ParseUint(a.Value, 10, 32)
+					if err != nil {
+						return err
+					}
+					tile.GID = GID(gid)
+				}
+			}
+			*tiles = append(*tiles, tile)
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// Buffers for decoding layer data, kept for reuse so that loading maps one after another does not allocate them anew.
+var buffers sync.Pool
+
+// Returns a buffer of n bytes from the pool; see putBuffer.
+func getBuffer(n int) *[]byte {
+	buf, _ := buffers.Get().(*[]byte)
+	if buf == nil || cap(*buf) < n {
+		b := make([]byte, n)
+		return &b
+	}
+	*buf = (*buf)[:n]
+	return buf
+}
+
+func putBuffer(buf *[]byte) {
+	buffers.Put(buf)
+}
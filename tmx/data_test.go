@@ -0,0 +1,172 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDataUnmarshal(t *testing.T) {
+	const data = `<data encoding="csv">
+1,2,
+3,4
+<chunk x="-4" y="8" width="2" height="1">
+5,
+6
+</chunk>
+</data>`
+
+	var d Data
+	if err := xml.Unmarshal([]byte(data), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Encoding != "csv" || string(d.RawData) != "1,2,3,4" {
+		t.Errorf("Wrong data %q %q", d.Encoding, d.RawData)
+	}
+	if len(d.Chunks) != 1 {
+		t.Fatal("Wrong number of chunks", len(d.Chunks))
+	}
+	if c := d.Chunks[0]; c.X != -4 || c.Y != 8 || c.Width != 2 || c.Height != 1 || string(c.RawData) != "5,6" {
+		t.Errorf("Wrong chunk %+v", c)
+	}
+
+	if err := xml.Unmarshal([]byte(`<data><tile/><tile gid="7"></tile></data>`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.DataTiles) != 2 || d.DataTiles[0].GID != 0 || d.DataTiles[1].GID != 7 || len(d.Chunks) != 0 {
+		t.Error("Wrong tiles", d.DataTiles)
+	}
+
+	// The text of other encodings is kept as is.
+	if err := xml.Unmarshal([]byte(`<data encoding="hex"> 1 2 </data>`), &d); err != nil {
+		t.Fatal(err)
+	}
+	if string(d.RawData) != " 1 2 " {
+		t.Errorf("Wrong data %q", d.RawData)
+	}
+}
+
+func TestDecodeCSV(t *testing.T) {
+	tests := []struct {
+		data string
+		n    int
+		gids []GID
+		err  error
+	}{
+		{"1,2, 3,\n4", 4, []GID{1, 2, 3, 4}, nil},
+		{"0,4294967295", 2, []GID{0, 4294967295}, nil},
+		{"", 0, []GID{}, nil},
+		{"1,2,3", 2, nil, InvalidDecodedDataLen},
+		{"1,2,3", 4, nil, InvalidDecodedDataLen},
+		{"1,,3", 3, nil, strconv.ErrSyntax},
+		{"1,2,", 3, nil, strconv.ErrSyntax},
+		{"1,-2", 2, nil, strconv.ErrSyntax},
+		{"1,4294967296", 2, nil, strconv.ErrRange},
+	}
+
+	for _, test := range tests {
+		d := Data{Encoding: "csv", RawData: []byte(test.data)}
+		gids, err := d.decode(test.n)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%q: wrong error %v", test.data, err)
+		} else if err == nil && !equalGIDs(gids, test.gids) {
+			t.Errorf("%q: wrong GIDs %v", test.data, gids)
+		}
+	}
+}
+
+func TestDecodeBase64Length(t *testing.T) {
+	for _, compression := range []string{"", "gzip", "zlib", "zstd"} {
+		s, err := encodeBase64([]GID{1, 2, 3}, compression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		d := Data{Encoding: "base64", Compression: compression, RawData: []byte(s)}
+		for _, n := range []int{2, 4} {
+			if _, err := d.decode(n); err != InvalidDecodedDataLen {
+				t.Error(compression, n, "wrong error", err)
+			}
+		}
+		if gids, err := d.decode(3); err != nil || !equalGIDs(gids, []GID{1, 2, 3}) {
+			t.Error(compression, "wrong GIDs", gids, err)
+		}
+	}
+}
+
+func TestDecodeHugeDimensions(t *testing.T) {
+	// A tiny map claiming 40 billion cells must fail without allocating for all of them.
+	for _, compression := range []string{"csv", "", "gzip", "zlib", "zstd"} {
+		encoding, data := "csv", "1,1"
+		if compression != "csv" {
+			s, err := encodeBase64([]GID{1, 1}, compression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encoding, data = "base64", s
+		}
+
+		tmx := fmt.Sprintf(`<map width="200000" height="200000"><tileset firstgid="1" name="ts" tilewidth="8" tileheight="8"/>`+
+			`<layer name="l"><data encoding="%s" compression="%s">%s</data></layer></map>`, encoding, compression, data)
+		if compression == "csv" {
+			tmx = strings.Replace(tmx, ` compression="csv"`, "", 1)
+		}
+		if _, err := Read(strings.NewReader(tmx)); !errors.Is(err, InvalidDecodedDataLen) {
+			t.Error(compression, "wrong error", err)
+		}
+	}
+}
+
+// Set when testing with the race detector, which makes sync.Pool drop items at random.
+var raceEnabled bool
+
+func TestDecodeAllocs(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool does not keep items with the race detector")
+	}
+
+	m, err := Read(bytes.NewReader(benchmarkMap(64, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &m.Layers[0]
+
+	csv := Data{Encoding: "csv", RawData: []byte(strings.Trim(strings.Replace(strings.Repeat("1,", 64*64), ",", ",\n", 64), ",\n"))}
+	for _, d := range []*Data{&l.Data, &csv} {
+		d.decode(64 * 64) // Fill the pools.
+		allocs := testing.AllocsPerRun(10, func() {
+			if _, err := d.decode(64 * 64); err != nil {
+				t.Fatal(err)
+			}
+		})
+		if allocs > 5 {
+			t.Error(d.Encoding, d.Compression, "allocations per decode:", allocs)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// An error along with where it was found. Errors of reading maps are of this type, and match their cause,
+// typically one of the errors of the package such as InvalidGID, with errors.Is.
+type Error struct {
+	File        string       // The map or other file, if known.
+	Layer       string       // Name of the layer or object group.
+	LayerIndex  int          // Index of the layer in Map.Layers, or of the object group in Map.ObjectGroups; -1 if none.
+	Object      string       // Name of the object.
+	ObjectID    ID           // ID of the object; 0 if none.
+	ObjectIndex int          // Index of the object in its group; -1 if none.
+	Cell        *image.Point // The cell of the layer, in tile coordinates, if known.
+	Offset      int64        // Byte offset in the layer (or chunk) data, white space left out, or in the points string; -1 if unknown.
+	Err         error        // The cause.
+
+	index int // Index of the cell in the data it was found in, turned into Cell by the layer; -1 if unknown.
+}
+
+func newError(err error) *Error {
+	return &Error{LayerIndex: -1, ObjectIndex: -1, Offset: -1, Err: err, index: -1}
+}
+
+// Returns err as an *Error, to add to its location.
+func located(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return newError(err)
+}
+
+// Adds the file to an error, unless it already has one.
+func inFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+	e := located(err)
+	if e.File == "" {
+		e.File = file
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("tmx: ")
+	if e.File != "" {
+		b.WriteString(e.File + ": ")
+	}
+
+	if e.Layer != "" || e.LayerIndex >= 0 {
+		b.WriteString("layer ")
+		if e.LayerIndex >= 0 {
+			fmt.Fprintf(&b, "%d ", e.LayerIndex)
+		}
+		fmt.Fprintf(&b, "%q: ", e.Layer)
+	}
+	if e.Object != "" || e.ObjectID != 0 || e.ObjectIndex >= 0 {
+		b.WriteString("object ")
+		if e.ObjectIndex >= 0 {
+			fmt.Fprintf(&b, "%d ", e.ObjectIndex)
+		}
+		fmt.Fprintf(&b, "%q (ID %d): ", e.Object, e.ObjectID)
+	}
+
+	if e.Cell != nil {
+		fmt.Fprintf(&b, "cell (%d, %d): ", e.Cell.X, e.Cell.Y)
+	}
+	if e.Offset >= 0 {
+		fmt.Fprintf(&b, "offset %d: ", e.Offset)
+	}
+
+	if e.Err != nil {
+		b.WriteString(strings.TrimPrefix(e.Err.Error(), "tmx: "))
+	}
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Locates an error of decoding the data of a layer, or of its chunk c, which is the layer with the given index.
+func (l *Layer) locate(err error, index int, c *Chunk) error {
+	e := located(err)
+	e.Layer, e.LayerIndex = l.Name, index
+	// Without a width, as in malformed maps, there is no telling the cell; the offset is all there is.
+	if e.index >= 0 && e.Cell == nil {
+		var p image.Point
+		switch {
+		case c == nil && l.Width > 0:
+			p = image.Pt(e.index%l.Width, e.index/l.Width)
+		case c != nil && c.Width > 0:
+			p = image.Pt(c.X+e.index%c.Width, c.Y+e.index/c.Width)
+		default:
+			return e
+		}
+		e.Cell = &p
+	}
+	return e
+}
+
+// An error of decoding layer data at the given byte offset, or of the cell with the given index; -1 if not known.
+func dataError(err error, offset, index int) *Error {
+	e := newError(err)
+	e.Offset, e.index = int64(offset), index
+	return e
+}
@@ -0,0 +1,111 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"errors"
+	"image"
+	"io/fs"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestError(t *testing.T) {
+	const tileset = `<tileset firstgid="1" name="ts" tilewidth="8" tileheight="8" tilecount="4" columns="2"/>`
+	fsys := fstest.MapFS{
+		"maps/csv.tmx": {Data: []byte(`<map width="3" height="2">` + tileset + `
+ <layer name="sky"><data encoding="csv">1,1,1,1,1,1</data></layer>
+ <layer name="ground"><data encoding="csv">1,1,1,
+1,x,1</data></layer>
+</map>`)},
+		"maps/chunk.tmx": {Data: []byte(`<map infinite="1" width="3" height="2">` + strings.Replace(tileset, `"1"`, `"3"`, 1) + `
+ <layer name="ground"><data encoding="csv"><chunk x="-4" y="2" width="2" height="1">3,1</chunk></data></layer>
+</map>`)},
+		"maps/base64.tmx": {Data: []byte(`<map width="1" height="1">` + tileset + `
+ <layer name="ground"><data encoding="base64">AQAA!AA=</data></layer>
+</map>`)},
+		"maps/missing.tmx": {Data: []byte(`<map width="1" height="1"><tileset firstgid="1" source="missing.tsx"/></map>`)},
+		"maps/nowidth.tmx": {Data: []byte(`<map><layer name="l"><data encoding="csv">x</data></layer></map>`)},
+		"maps/chunk-nowidth.tmx": {Data: []byte(`<map infinite="1">
+ <layer name="l"><data encoding="csv"><chunk x="0" y="0" width="0" height="4">x</chunk></data></layer>
+</map>`)},
+	}
+
+	tests := []struct {
+		file   string
+		cause  error
+		want   Error
+		cell   *image.Point
+		errstr string
+	}{
+		{"maps/csv.tmx", strconv.ErrSyntax, Error{File: "maps/csv.tmx", Layer: "ground", LayerIndex: 1, ObjectIndex: -1, Offset: 8}, &image.Point{1, 1},
+			`tmx: maps/csv.tmx: layer 1 "ground": cell (1, 1): offset 8: strconv.ParseUint: parsing "x": invalid syntax`},
+		{"maps/chunk.tmx", InvalidGID, Error{File: "maps/chunk.tmx", Layer: "ground", LayerIndex: 0, ObjectIndex: -1, Offset: -1}, &image.Point{-3, 2},
+			`tmx: maps/chunk.tmx: layer 0 "ground": cell (-3, 2): invalid GID`},
+		{"maps/base64.tmx", nil, Error{File: "maps/base64.tmx", Layer: "ground", LayerIndex: 0, ObjectIndex: -1, Offset: 4}, nil, ""},
+		{"maps/missing.tmx", fs.ErrNotExist, Error{File: "maps/missing.tsx", LayerIndex: -1, ObjectIndex: -1, Offset: -1}, nil, ""},
+		// Without a width, cells are not known.
+		{"maps/nowidth.tmx", strconv.ErrSyntax, Error{File: "maps/nowidth.tmx", Layer: "l", LayerIndex: 0, ObjectIndex: -1, Offset: 0}, nil, ""},
+		{"maps/chunk-nowidth.tmx", strconv.ErrSyntax, Error{File: "maps/chunk-nowidth.tmx", Layer: "l", LayerIndex: 0, ObjectIndex: -1, Offset: 0}, nil, ""},
+	}
+
+	for _, test := range tests {
+		_, err := (&Loader{FS: fsys}).ReadFile(test.file)
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Error(test.file, "wrong error", err)
+			continue
+		}
+		if test.cause != nil && !errors.Is(err, test.cause) {
+			t.Error(test.file, "error does not match its cause", err)
+		}
+		if test.errstr != "" && err.Error() != test.errstr {
+			t.Error(test.file, "wrong message", err)
+		}
+		if (e.Cell == nil) != (test.cell == nil) || e.Cell != nil && *e.Cell != *test.cell {
+			t.Error(test.file, "wrong cell", e.Cell)
+		}
+		e.Cell, e.Err, e.index = nil, nil, 0
+		if *e != test.want {
+			t.Errorf("%s: wrong location %+v", test.file, *e)
+		}
+	}
+}
+
+func TestErrorPoints(t *testing.T) {
+	o := Object{ID: 7, Name: "ledge", Polygons: []Polygon{{Points: "0,0 1,2  3,y"}}}
+	_, err := o.Points()
+
+	var e *Error
+	if !errors.Is(err, InvalidPointsField) || !errors.As(err, &e) {
+		t.Fatal("Wrong error", err)
+	}
+	if e.Object != "ledge" || e.ObjectID != 7 || e.Offset != 11 {
+		t.Errorf("Wrong location %+v", *e)
+	}
+	if !strings.Contains(err.Error(), `object "ledge" (ID 7): offset 11`) {
+		t.Error("Wrong message", err)
+	}
+}
@@ -91,7 +91,7 @@ func Do(c Console, filename string) error {
 			return TooManyTiles
 		}
 
-		compression, _ := GetProperty(l.Properties, "Compression")
+		compression, _ := l.Properties.String("Compression")
 		if compression != "" {
 			compressionMethod, ok := CompressionMethods[compression]
 			if !ok {
@@ -101,10 +101,11 @@ func Do(c Console, filename string) error {
 			defer b.Close()
 		}
 
+		tiles := l.DecodedTiles()
 		i := 0
 		for y := 0; y < m.Height; y++ {
 			for x := 0; x < m.Width; x++ {
-				tile, err := c.ScreenblockEntry(m, l, l.DecodedTiles[i])
+				tile, err := c.ScreenblockEntry(m, l, tiles[i])
 				if err != nil {
 					return err
 				}
@@ -124,7 +125,7 @@ func Do(c Console, filename string) error {
 		var d uint8
 		for y := 0; y < m.Height; y++ {
 			for x := 0; x < m.Width; x++ {
-				if l.DecodedTiles[i].Nil == false {
+				if l.GIDs[i] != 0 {
 					d |= 1 << i
 				}
 				i++
@@ -144,7 +145,7 @@ func Do(c Console, filename string) error {
 	for i := 0; i < len(m.Layers); i++ {
 		l := &m.Layers[i]
 
-		bitmap, err := GetProperty(l.Properties, "Bitmap")
+		bitmap, _ := l.Properties.Bool("Bitmap")
 
 		name := filenameBare + "." + l.Name + ".layer"
 		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0666)
@@ -153,7 +154,7 @@ func Do(c Console, filename string) error {
 		}
 		defer f.Close()
 
-		if bitmap == "true" {
+		if bitmap {
 			if err := saveLayerBitmap(l, f); err != nil {
 				return err
 			}
@@ -0,0 +1,74 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+// Maps are not expected to have more tiles than this; the tilesets of larger ones are looked up by a search.
+const maxGIDTable = 1 << 20
+
+// The tileset of each GID below the FirstGID of the last tileset, which has all GIDs from there on.
+// A table belongs to the Tilesets slice it was built from, and is not used once that is replaced.
+type gidTable struct {
+	tilesets []Tileset
+	lookup   []*Tileset
+}
+
+// Returns nil if the tilesets are not sorted by FirstGID, as they are in maps written by Tiled, or if there are
+// too many GIDs.
+func newGIDTable(tilesets []Tileset) *gidTable {
+	if len(tilesets) == 0 || tilesets[len(tilesets)-1].FirstGID > maxGIDTable {
+		return nil
+	}
+
+	t := &gidTable{tilesets: tilesets, lookup: make([]*Tileset, tilesets[len(tilesets)-1].FirstGID)}
+	for i := 0; i < len(tilesets)-1; i++ {
+		first, next := tilesets[i].FirstGID, tilesets[i+1].FirstGID
+		if next < first {
+			return nil
+		}
+		for gid := first; gid < next; gid++ {
+			t.lookup[gid] = &tilesets[i]
+		}
+	}
+	return t
+}
+
+// Returns the tileset a GID, without flip bits, belongs to, or nil if there is none.
+func (m *Map) tilesetOf(gid GID) *Tileset {
+	if len(m.Tilesets) == 0 {
+		return nil
+	}
+
+	if t := m.gids; t != nil && len(t.tilesets) == len(m.Tilesets) && &t.tilesets[0] == &m.Tilesets[0] {
+		if int(gid) < len(t.lookup) {
+			return t.lookup[gid]
+		}
+		return &m.Tilesets[len(m.Tilesets)-1]
+	}
+
+	for i := len(m.Tilesets) - 1; i >= 0; i-- {
+		if m.Tilesets[i].FirstGID <= gid {
+			return &m.Tilesets[i]
+		}
+	}
+	return nil
+}
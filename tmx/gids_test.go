@@ -0,0 +1,93 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeGID(t *testing.T) {
+	m, err := Read(bytes.NewReader(benchmarkMap(4, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.gids == nil {
+		t.Fatal("No GID table")
+	}
+
+	for gid := GID(1); gid < 8*28+100; gid++ {
+		tile, err := m.DecodeGID(gid | GIDVerticalFlip)
+		if err != nil {
+			t.Fatal(gid, err)
+		}
+
+		i := int(gid-1) / 28
+		if i > 7 {
+			i = 7
+		}
+		if tile.Tileset != &m.Tilesets[i] || tile.ID != ID(gid-m.Tilesets[i].FirstGID) || !tile.VerticalFlip || tile.HorizontalFlip {
+			t.Error("Wrong tile for GID", gid, tile)
+		}
+	}
+
+	// A table is not used for tilesets it was not built from.
+	m.Tilesets = append([]Tileset{{FirstGID: 1, Name: "new"}}, m.Tilesets[:1]...)
+	m.Tilesets[1].FirstGID = 50
+	if tile, _ := m.DecodeGID(49); tile.Tileset != &m.Tilesets[0] {
+		t.Error("Stale GID table used")
+	}
+
+	m.Tilesets[0].FirstGID = 2
+	if _, err := m.DecodeGID(1); err != InvalidGID {
+		t.Error("Wrong error for a GID without tileset", err)
+	}
+}
+
+func TestDecodedTiles(t *testing.T) {
+	m, err := ReadFile("testdata/csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &m.Layers[0]
+	tiles := l.DecodedTiles()
+	if len(tiles) != len(layer0Data) {
+		t.Fatal("Wrong number of tiles", len(tiles))
+	}
+	for i, tile := range tiles {
+		if tile.GID() != layer0Data[i] || *tile != *l.TileAt(i%l.Width, i/l.Width) {
+			t.Fatal("Wrong tile at", i)
+		}
+	}
+	if tiles[14] != tiles[16] {
+		t.Error("Tiles of the same GID are not shared")
+	}
+	if l.GIDAt(-1, 0) != 0 || !l.TileAt(l.Width, 0).IsNil() {
+		t.Error("Tile outside of the layer")
+	}
+
+	if tiles := new(Layer).DecodedTiles(); len(tiles) != 0 {
+		t.Error("Tiles in an empty layer")
+	}
+}
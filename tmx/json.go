@@ -0,0 +1,708 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tiled's JSON map format (TMJ), as far as this package models it.
+type jsonMap struct {
+	Type            string        `json:"type"`
+	Version         jsonString    `json:"version"`
+	TiledVersion    string        `json:"tiledversion,omitempty"`
+	Class           string        `json:"class,omitempty"`
+	Orientation     string        `json:"orientation"`
+	RenderOrder     string        `json:"renderorder,omitempty"`
+	Width           int           `json:"width"`
+	Height          int           `json:"height"`
+	TileWidth       int           `json:"tilewidth"`
+	TileHeight      int           `json:"tileheight"`
+	HexSideLength   int           `json:"hexsidelength,omitempty"`
+	StaggerAxis     string        `json:"staggeraxis,omitempty"`
+	StaggerIndex    string        `json:"staggerindex,omitempty"`
+	ParallaxOriginX float64       `json:"parallaxoriginx,omitempty"`
+	ParallaxOriginY float64       `json:"parallaxoriginy,omitempty"`
+	BackgroundColor string        `json:"backgroundcolor,omitempty"`
+	NextLayerID     ID            `json:"nextlayerid,omitempty"`
+	NextObjectID    ID            `json:"nextobjectid,omitempty"`
+	Infinite        bool          `json:"infinite"`
+	Properties      jsonProps     `json:"properties,omitempty"`
+	Tilesets        []jsonTileset `json:"tilesets"`
+	Layers          []jsonLayer   `json:"layers"`
+}
+
+// The JSON tileset format (TSJ), also used for tilesets embedded in maps.
+type jsonTileset struct {
+	Type             string           `json:"type,omitempty"`
+	FirstGID         GID              `json:"firstgid,omitempty"`
+	Source           string           `json:"source,omitempty"`
+	Name             string           `json:"name,omitempty"`
+	Class            string           `json:"class,omitempty"`
+	TileWidth        int              `json:"tilewidth,omitempty"`
+	TileHeight       int              `json:"tileheight,omitempty"`
+	Spacing          int              `json:"spacing,omitempty"`
+	Margin           int              `json:"margin,omitempty"`
+	TileCount        int              `json:"tilecount,omitempty"`
+	Columns          int              `json:"columns,omitempty"`
+	ObjectAlignment  string           `json:"objectalignment,omitempty"`
+	TileRenderSize   string           `json:"tilerendersize,omitempty"`
+	FillMode         string           `json:"fillmode,omitempty"`
+	TileOffset       *TileOffset      `json:"tileoffset,omitempty"`
+	Grid             *Grid            `json:"grid,omitempty"`
+	Transformations  *Transformations `json:"transformations,omitempty"`
+	Image            string           `json:"image,omitempty"`
+	ImageWidth       int              `json:"imagewidth,omitempty"`
+	ImageHeight      int              `json:"imageheight,omitempty"`
+	TransparentColor string           `json:"transparentcolor,omitempty"`
+	Properties       jsonProps        `json:"properties,omitempty"`
+	Tiles            []jsonTile       `json:"tiles,omitempty"`
+	WangSets         []jsonWangSet    `json:"wangsets,omitempty"`
+	Terrains         []jsonWangColor  `json:"terrains,omitempty"` // Replaced by wang sets in Tiled 1.5.
+}
+
+type jsonTile struct {
+	ID          ID         `json:"id"`
+	Type        string     `json:"type,omitempty"`
+	Class       string     `json:"class,omitempty"` // Tiled 1.9 names the type class.
+	Probability *float64   `json:"probability,omitempty"`
+	X           int        `json:"x,omitempty"`
+	Y           int        `json:"y,omitempty"`
+	Width       int        `json:"width,omitempty"`
+	Height      int        `json:"height,omitempty"`
+	Properties  jsonProps  `json:"properties,omitempty"`
+	Image       string     `json:"image,omitempty"`
+	ImageWidth  int        `json:"imagewidth,omitempty"`
+	ImageHeight int        `json:"imageheight,omitempty"`
+	Animation   []Frame    `json:"animation,omitempty"`
+	ObjectGroup *jsonLayer `json:"objectgroup,omitempty"`
+	Terrain     []int      `json:"terrain,omitempty"` // Replaced by wang sets in Tiled 1.5.
+}
+
+type jsonWangSet struct {
+	Name       string          `json:"name"`
+	Class      string          `json:"class,omitempty"`
+	Type       string          `json:"type"`
+	Tile       *int            `json:"tile"`
+	Properties jsonProps       `json:"properties,omitempty"`
+	Colors     []jsonWangColor `json:"colors"`
+	Tiles      []WangTile      `json:"wangtiles"`
+
+	EdgeColors   []jsonWangColor `json:"edgecolors,omitempty"`   // Replaced by colors in Tiled 1.5.
+	CornerColors []jsonWangColor `json:"cornercolors,omitempty"` // Replaced by colors in Tiled 1.5.
+}
+
+type jsonWangColor struct {
+	Name        string    `json:"name"`
+	Class       string    `json:"class,omitempty"`
+	Color       string    `json:"color,omitempty"`
+	Tile        *int      `json:"tile"`
+	Probability *float64  `json:"probability,omitempty"`
+	Properties  jsonProps `json:"properties,omitempty"`
+}
+
+// All kinds of layers share one JSON object, told apart by Type.
+type jsonLayer struct {
+	Type       string    `json:"type"`
+	ID         ID        `json:"id"`
+	Name       string    `json:"name"`
+	Opacity    *float32  `json:"opacity"`
+	Visible    *bool     `json:"visible"`
+	Class      string    `json:"class,omitempty"`
+	Locked     bool      `json:"locked,omitempty"`
+	OffsetX    float64   `json:"offsetx,omitempty"`
+	OffsetY    float64   `json:"offsety,omitempty"`
+	ParallaxX  *float64  `json:"parallaxx,omitempty"`
+	ParallaxY  *float64  `json:"parallaxy,omitempty"`
+	TintColor  string    `json:"tintcolor,omitempty"`
+	Properties jsonProps `json:"properties,omitempty"`
+	X          int       `json:"x"` // Always 0.
+	Y          int       `json:"y"` // Always 0.
+
+	// Tile layers
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	Encoding    string          `json:"encoding,omitempty"`
+	Compression string          `json:"compression,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Chunks      []jsonChunk     `json:"chunks,omitempty"`
+
+	// Object groups
+	Color   string       `json:"color,omitempty"`
+	Objects []jsonObject `json:"objects,omitempty"`
+
+	// Image layers
+	Image            string `json:"image,omitempty"`
+	ImageWidth       int    `json:"imagewidth,omitempty"`
+	ImageHeight      int    `json:"imageheight,omitempty"`
+	TransparentColor string `json:"transparentcolor,omitempty"`
+	RepeatX          bool   `json:"repeatx,omitempty"`
+	RepeatY          bool   `json:"repeaty,omitempty"`
+
+	// Groups
+	Layers []jsonLayer `json:"layers,omitempty"`
+}
+
+type jsonChunk struct {
+	X      int             `json:"x"`
+	Y      int             `json:"y"`
+	Width  int             `json:"width"`
+	Height int             `json:"height"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type jsonObject struct {
+	ID         ID        `json:"id"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"`
+	Class      string    `json:"class,omitempty"`
+	X          float64   `json:"x"`
+	Y          float64   `json:"y"`
+	Width      float64   `json:"width"`
+	Height     float64   `json:"height"`
+	Rotation   float64   `json:"rotation"`
+	GID        int64     `json:"gid,omitempty"`
+	Visible    *bool     `json:"visible"`
+	Template   string    `json:"template,omitempty"`
+	Ellipse    bool      `json:"ellipse,omitempty"`
+	Point      bool      `json:"point,omitempty"`
+	Polygon    []Point   `json:"polygon,omitempty"`
+	Polyline   []Point   `json:"polyline,omitempty"`
+	Text       *jsonText `json:"text,omitempty"`
+	Properties jsonProps `json:"properties,omitempty"`
+}
+
+type jsonText struct {
+	Text       string `json:"text"`
+	FontFamily string `json:"fontfamily,omitempty"`
+	PixelSize  int    `json:"pixelsize,omitempty"`
+	Wrap       bool   `json:"wrap"`
+	Color      string `json:"color,omitempty"`
+	Bold       bool   `json:"bold,omitempty"`
+	Italic     bool   `json:"italic,omitempty"`
+	Underline  bool   `json:"underline,omitempty"`
+	Strikeout  bool   `json:"strikeout,omitempty"`
+	Kerning    *bool  `json:"kerning,omitempty"`
+	HAlign     string `json:"halign,omitempty"`
+	VAlign     string `json:"valign,omitempty"`
+}
+
+type jsonTemplate struct {
+	Tileset *jsonTileset `json:"tileset"`
+	Object  jsonObject   `json:"object"`
+}
+
+type jsonProps []jsonProperty
+
+type jsonProperty struct {
+	Name         string          `json:"name"`
+	Type         string          `json:"type"`
+	PropertyType string          `json:"propertytype,omitempty"`
+	Value        json.RawMessage `json:"value"`
+}
+
+// Older versions of Tiled wrote the version as a number.
+type jsonString string
+
+func (s *jsonString) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, (*string)(s))
+	}
+	*s = jsonString(b)
+	return nil
+}
+
+// Reports whether the data r is about to return looks like JSON, rather than XML.
+func isJSON(r *bufio.Reader) bool {
+	for i := 1; ; i++ {
+		b, err := r.Peek(i)
+		if err != nil {
+			return false
+		}
+		switch b[i-1] {
+		case ' ', '\t', '\r', '\n', 0xef, 0xbb, 0xbf: // Whitespace and the UTF-8 byte order mark.
+			continue
+		}
+		return b[i-1] == '{'
+	}
+}
+
+func readJSONMap(r io.Reader) (*Map, error) {
+	var jm jsonMap
+	if err := json.NewDecoder(r).Decode(&jm); err != nil {
+		return nil, err
+	}
+
+	m := &Map{
+		Version:         string(jm.Version),
+		TiledVersion:    jm.TiledVersion,
+		Class:           jm.Class,
+		Orientation:     jm.Orientation,
+		RenderOrder:     jm.RenderOrder,
+		Width:           jm.Width,
+		Height:          jm.Height,
+		TileWidth:       jm.TileWidth,
+		TileHeight:      jm.TileHeight,
+		HexSideLength:   jm.HexSideLength,
+		StaggerAxis:     jm.StaggerAxis,
+		StaggerIndex:    jm.StaggerIndex,
+		ParallaxOriginX: jm.ParallaxOriginX,
+		ParallaxOriginY: jm.ParallaxOriginY,
+		BackgroundColor: jm.BackgroundColor,
+		NextLayerID:     jm.NextLayerID,
+		NextObjectID:    jm.NextObjectID,
+		Infinite:        jm.Infinite,
+		Properties:      jm.Properties.properties(),
+	}
+	if m.RenderOrder == "" {
+		m.RenderOrder = "right-down"
+	}
+
+	for i := range jm.Tilesets {
+		m.Tilesets = append(m.Tilesets, *jm.Tilesets[i].tileset())
+	}
+
+	var err error
+	if m.LayerTree, err = jsonLayerNodes(jm.Layers); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func readJSONTileset(r io.Reader) (*Tileset, error) {
+	var jts jsonTileset
+	if err := json.NewDecoder(r).Decode(&jts); err != nil {
+		return nil, err
+	}
+	return jts.tileset(), nil
+}
+
+func readJSONTemplate(r io.Reader) (*template, error) {
+	var jt jsonTemplate
+	if err := json.NewDecoder(r).Decode(&jt); err != nil {
+		return nil, err
+	}
+
+	t := &template{Object: jt.Object.object()}
+	if jt.Tileset != nil {
+		t.Tileset = jt.Tileset.tileset()
+	}
+	return t, nil
+}
+
+func (jts *jsonTileset) tileset() *Tileset {
+	ts := &Tileset{
+		FirstGID:   jts.FirstGID,
+		Source:     jts.Source,
+		Name:       jts.Name,
+		Class:      jts.Class,
+		TileWidth:  jts.TileWidth,
+		TileHeight: jts.TileHeight,
+		Spacing:    jts.Spacing,
+		Margin:     jts.Margin,
+		Tilecount:  jts.TileCount,
+		Columns:    jts.Columns,
+		Properties: jts.Properties.properties(),
+		Image:      jsonImage(jts.Image, jts.ImageWidth, jts.ImageHeight, jts.TransparentColor),
+	}
+	ts.defaults()
+	if jts.ObjectAlignment != "" {
+		ts.ObjectAlignment = jts.ObjectAlignment
+	}
+	if jts.TileRenderSize != "" {
+		ts.TileRenderSize = jts.TileRenderSize
+	}
+	if jts.FillMode != "" {
+		ts.FillMode = jts.FillMode
+	}
+	if jts.TileOffset != nil {
+		ts.TileOffset = *jts.TileOffset
+	}
+	if jts.Grid != nil {
+		ts.Grid = *jts.Grid
+	}
+	if jts.Transformations != nil {
+		ts.Transformations = *jts.Transformations
+	}
+
+	for _, jt := range jts.Tiles {
+		t := Tile{
+			ID:          jt.ID,
+			Type:        jt.Type,
+			Probability: jsonFloat(jt.Probability, 1),
+			X:           jt.X,
+			Y:           jt.Y,
+			Width:       jt.Width,
+			Height:      jt.Height,
+			Properties:  jt.Properties.properties(),
+			Image:       jsonImage(jt.Image, jt.ImageWidth, jt.ImageHeight, ""),
+			Animation:   jt.Animation,
+		}
+		if t.Type == "" {
+			t.Type = jt.Class
+		}
+		if jt.Terrain != nil {
+			t.terrain = jt.Terrain
+		}
+		if jt.ObjectGroup != nil {
+			g := jt.ObjectGroup.objectGroup()
+			t.ObjectGroup = &g
+		}
+		ts.Tiles = append(ts.Tiles, t)
+	}
+
+	for _, jws := range jts.WangSets {
+		ws := WangSet{
+			Name:       jws.Name,
+			Class:      jws.Class,
+			Type:       jws.Type,
+			Tile:       jsonInt(jws.Tile, -1),
+			Properties: jws.Properties.properties(),
+			Tiles:      jws.Tiles,
+		}
+		for i := range jws.Colors {
+			ws.Colors = append(ws.Colors, jws.Colors[i].wangColor())
+		}
+		var edges, corners []WangColor
+		for i := range jws.EdgeColors {
+			edges = append(edges, jws.EdgeColors[i].wangColor())
+		}
+		for i := range jws.CornerColors {
+			corners = append(corners, jws.CornerColors[i].wangColor())
+		}
+		ws.convertColors(edges, corners)
+		ts.WangSets = append(ts.WangSets, ws)
+	}
+
+	var terrains []WangColor
+	for i := range jts.Terrains {
+		terrains = append(terrains, jts.Terrains[i].wangColor())
+	}
+	ts.convertTerrains(terrains)
+	return ts
+}
+
+func (jc *jsonWangColor) wangColor() WangColor {
+	return WangColor{
+		Name:        jc.Name,
+		Class:       jc.Class,
+		Color:       jc.Color,
+		Tile:        jsonInt(jc.Tile, -1),
+		Probability: jsonFloat(jc.Probability, 1),
+		Properties:  jc.Properties.properties(),
+	}
+}
+
+func jsonImage(source string, width, height int, trans string) Image {
+	return Image{Source: source, Width: width, Height: height, Trans: strings.TrimPrefix(trans, "#")}
+}
+
+func jsonLayerNodes(layers []jsonLayer) ([]LayerNode, error) {
+	nodes := make([]LayerNode, 0, len(layers))
+	for i := range layers {
+		jl := &layers[i]
+
+		var n LayerNode
+		switch jl.Type {
+		case "tilelayer":
+			l, err := jl.layer()
+			if err != nil {
+				return nil, err
+			}
+			n.Layer = l
+		case "objectgroup":
+			g := jl.objectGroup()
+			n.ObjectGroup = &g
+		case "imagelayer":
+			n.ImageLayer = &ImageLayer{
+				LayerBase: jl.base(),
+				Image:     jsonImage(jl.Image, jl.ImageWidth, jl.ImageHeight, jl.TransparentColor),
+				RepeatX:   jl.RepeatX,
+				RepeatY:   jl.RepeatY,
+			}
+		case "group":
+			children, err := jsonLayerNodes(jl.Layers)
+			if err != nil {
+				return nil, err
+			}
+			n.Group = &Group{LayerBase: jl.base(), Children: children}
+		default:
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+func jsonFloat(v *float64, def float64) float64 {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func jsonInt(v *int, def int) int {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+func (jl *jsonLayer) base() LayerBase {
+	b := LayerBase{
+		ID:         jl.ID,
+		Name:       jl.Name,
+		Opacity:    1,
+		Visible:    true,
+		Class:      jl.Class,
+		Locked:     jl.Locked,
+		OffsetX:    jl.OffsetX,
+		OffsetY:    jl.OffsetY,
+		ParallaxX:  jsonFloat(jl.ParallaxX, 1),
+		ParallaxY:  jsonFloat(jl.ParallaxY, 1),
+		TintColor:  jl.TintColor,
+		Properties: jl.Properties.properties(),
+	}
+	if jl.Opacity != nil {
+		b.Opacity = *jl.Opacity
+	}
+	if jl.Visible != nil {
+		b.Visible = *jl.Visible
+	}
+	return b
+}
+
+// Layer data is stored the same way as in TMX files, so that it is decoded the same way:
+// arrays of GIDs (the "csv" encoding of JSON maps) as CSV, and base64 data as is.
+func (jl *jsonLayer) layer() (*Layer, error) {
+	l := &Layer{
+		LayerBase: jl.base(),
+		Width:     jl.Width,
+		Height:    jl.Height,
+		Data:      Data{Encoding: jl.Encoding, Compression: jl.Compression},
+	}
+	if l.Data.Encoding == "" {
+		l.Data.Encoding = "csv"
+	}
+
+	var err error
+	if jl.Data != nil {
+		if l.Data.RawData, err = jsonData(jl.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, jc := range jl.Chunks {
+		c := Chunk{X: jc.X, Y: jc.Y, Width: jc.Width, Height: jc.Height}
+		if c.RawData, err = jsonData(jc.Data); err != nil {
+			return nil, err
+		}
+		l.Data.Chunks = append(l.Data.Chunks, c)
+	}
+	return l, nil
+}
+
+func jsonData(data json.RawMessage) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+
+	var gids []GID
+	if err := json.Unmarshal(data, &gids); err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	for i, gid := range gids {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(gid), 10)
+	}
+	return b, nil
+}
+
+func (jl *jsonLayer) objectGroup() ObjectGroup {
+	g := ObjectGroup{LayerBase: jl.base(), Color: jl.Color}
+	for i := range jl.Objects {
+		g.Objects = append(g.Objects, jl.Objects[i].object())
+	}
+	return g
+}
+
+func (jo *jsonObject) object() Object {
+	o := Object{
+		ID:         jo.ID,
+		Name:       jo.Name,
+		Type:       jo.Type,
+		X:          jo.X,
+		Y:          jo.Y,
+		Width:      jo.Width,
+		Height:     jo.Height,
+		Rotation:   jo.Rotation,
+		GID:        int(jo.GID),
+		Visible:    jo.Visible == nil || *jo.Visible,
+		Template:   jo.Template,
+		Properties: jo.Properties.properties(),
+	}
+	if o.Type == "" {
+		o.Type = jo.Class
+	}
+	if jo.Ellipse {
+		o.Ellipse = &struct{}{}
+	}
+	if jo.Point {
+		o.Point = &struct{}{}
+	}
+	if jo.Polygon != nil {
+		o.Polygons = []Polygon{{Points: encodePoints(jo.Polygon)}}
+	}
+	if jo.Polyline != nil {
+		o.PolyLines = []PolyLine{{Points: encodePoints(jo.Polyline)}}
+	}
+
+	if jt := jo.Text; jt != nil {
+		o.Text = &Text{
+			Text:       jt.Text,
+			FontFamily: jt.FontFamily,
+			PixelSize:  jt.PixelSize,
+			Wrap:       jt.Wrap,
+			Color:      jt.Color,
+			Bold:       jt.Bold,
+			Italic:     jt.Italic,
+			Underline:  jt.Underline,
+			Strikeout:  jt.Strikeout,
+			Kerning:    jt.Kerning == nil || *jt.Kerning,
+			HAlign:     jt.HAlign,
+			VAlign:     jt.VAlign,
+		}
+		// Defaults, omitted by Tiled.
+		if o.Text.FontFamily == "" {
+			o.Text.FontFamily = "sans-serif"
+		}
+		if o.Text.PixelSize == 0 {
+			o.Text.PixelSize = 16
+		}
+		if o.Text.Color == "" {
+			o.Text.Color = "#000000"
+		}
+		if o.Text.HAlign == "" {
+			o.Text.HAlign = "left"
+		}
+		if o.Text.VAlign == "" {
+			o.Text.VAlign = "top"
+		}
+	}
+	return o
+}
+
+// Encodes points the way they are stored in TMX files.
+func encodePoints(points []Point) string {
+	var b []byte
+	for i, p := range points {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = strconv.AppendFloat(b, p.X, 'g', -1, 64)
+		b = append(b, ',')
+		b = strconv.AppendFloat(b, p.Y, 'g', -1, 64)
+	}
+	return string(b)
+}
+
+func (jps jsonProps) properties() Properties {
+	if len(jps) == 0 {
+		return nil
+	}
+
+	ps := make(Properties, len(jps))
+	for i, jp := range jps {
+		ps[i] = Property{Name: jp.Name, Type: jp.Type, PropertyType: jp.PropertyType}
+		if ps[i].Type == "string" {
+			ps[i].Type = "" // As in TMX files, where Tiled leaves the type of strings out.
+		}
+		ps[i].Value, ps[i].Properties = jsonValue(jp.Value)
+	}
+	return ps
+}
+
+// Converts a property value into its TMX form. Class values become member properties,
+// sorted by name, as JSON objects are unordered; their types are guessed from their values.
+func jsonValue(v json.RawMessage) (string, Properties) {
+	v = bytes.TrimSpace(v)
+	if len(v) == 0 {
+		return "", nil
+	}
+
+	switch v[0] {
+	case '"':
+		var s string
+		json.Unmarshal(v, &s)
+		return s, nil
+	case '{':
+		var members map[string]json.RawMessage
+		json.Unmarshal(v, &members)
+
+		names := make([]string, 0, len(members))
+		for name := range members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		ps := make(Properties, len(names))
+		for i, name := range names {
+			ps[i].Name = name
+			ps[i].Value, ps[i].Properties = jsonValue(members[name])
+			ps[i].Type = jsonType(members[name], ps[i].Value)
+		}
+		return "", ps
+	}
+	return string(v), nil // Numbers and booleans
+}
+
+// Guesses the type of a class member, which is not stored in JSON files, from its value.
+func jsonType(v json.RawMessage, s string) string {
+	v = bytes.TrimSpace(v)
+	switch {
+	case len(v) == 0 || v[0] == '"':
+		return ""
+	case v[0] == '{':
+		return "class"
+	case s == "true" || s == "false":
+		return "bool"
+	case strings.ContainsAny(s, ".eE"):
+		return "float"
+	}
+	return "int"
+}
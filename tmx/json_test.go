@@ -0,0 +1,155 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"testing"
+)
+
+func TestJSONLayers(t *testing.T) {
+	pairs := []struct{ tmx, json string }{
+		{"testdata/csv.tmx", "testdata/csv.tmj"},
+		{"testdata/base64-zlib.tmx", "testdata/base64-zlib.tmj"},
+		{"testdata/infinite.tmx", "testdata/infinite.tmj"},
+	}
+
+	for _, pair := range pairs {
+		xm, err := ReadFile(pair.tmx)
+		if err != nil {
+			t.Fatal(pair.tmx, err)
+		}
+		jm, err := ReadFile(pair.json)
+		if err != nil {
+			t.Fatal(pair.json, err)
+		}
+
+		if jm.Width != xm.Width || jm.Height != xm.Height || jm.TileWidth != xm.TileWidth || jm.Infinite != xm.Infinite {
+			t.Error(pair.json, "wrong map attributes")
+		}
+		if jm.Tilesets[0].Name != "default" || jm.Tilesets[0].Image.Width != 112 || jm.Tilesets[0].Image.Path != "testdata/tiles.png" {
+			t.Error(pair.json, "wrong tileset", jm.Tilesets[0])
+		}
+
+		if len(jm.Layers) == 0 {
+			t.Fatal(pair.json, "no layers")
+		}
+		for i := range jm.Layers {
+			jl := &jm.Layers[i]
+			xl := xm.LayerByPath(jl.Name)
+			if xl == nil || xl.Layer == nil {
+				t.Fatal(pair.json, "layer", jl.Name, "not in", pair.tmx)
+			}
+
+			if !equalGIDs(layerGIDs(jl), layerGIDs(xl.Layer)) {
+				t.Error(pair.json, "layer", jl.Name, "differs from", pair.tmx)
+			}
+			if jl.Bounds() != xl.Layer.Bounds() {
+				t.Error(pair.json, "layer", jl.Name, "has wrong bounds", jl.Bounds())
+			}
+		}
+	}
+}
+
+func TestJSONObjects(t *testing.T) {
+	m, err := ReadFile("testdata/objects.tmj")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Version != "1.10" {
+		t.Error("Wrong version", m.Version)
+	}
+
+	group := m.LayerByPath("Group")
+	if group == nil || group.Group == nil || group.Group.Visible || group.Group.Opacity != 0.5 {
+		t.Fatal("Wrong group", group)
+	}
+
+	backdrop := m.LayerByPath("Group/Backdrop")
+	if backdrop == nil || backdrop.ImageLayer == nil {
+		t.Fatal("No image layer")
+	}
+	if l := backdrop.ImageLayer; l.Image.Path != "testdata/tiles.png" || !l.RepeatX || l.ParallaxX != 0.5 || l.ParallaxY != 1 || l.EffectiveOpacity() != 0.25 {
+		t.Error("Wrong image layer", l)
+	}
+
+	shapes := []Shape{ShapeRectangle, ShapeEllipse, ShapePoint, ShapePolygon, ShapeText, ShapeTile}
+	objects := m.ObjectGroups[0].Objects
+	if len(objects) != len(shapes) {
+		t.Fatal("Wrong number of objects", len(objects))
+	}
+	for i := range objects {
+		if s := objects[i].Shape(); s != shapes[i] {
+			t.Error("Wrong shape", s, "for", objects[i].Name)
+		}
+	}
+
+	if o := m.ObjectByID(1); o.Rotation != 45 || o.Width != 3 || !o.Visible {
+		t.Error("Wrong rectangle", o)
+	}
+	if points, err := m.ObjectByID(4).Points(); err != nil || len(points) != 3 || points[1] != (Point{12.5, 3.25}) {
+		t.Error("Wrong polygon", points, err)
+	}
+
+	label := m.ObjectByID(5)
+	if label.Visible || label.Text.Text != "Hello, world" || label.Text.PixelSize != 12 || !label.Text.Kerning || label.Text.VAlign != "top" {
+		t.Error("Wrong text", label, label.Text)
+	}
+
+	crate := m.ObjectByID(6)
+	if crate.GID != 3 || crate.Type != "container" {
+		t.Error("Wrong tile object", crate)
+	}
+	if o, err := crate.Properties.Object("target"); o != label || err != nil {
+		t.Error("Wrong object property", o, err)
+	}
+
+	ps := m.Properties
+	if v, err := ps.Bool("dark"); !v || err != nil {
+		t.Error("Wrong bool", v, err)
+	}
+	if v, err := ps.Float("gravity"); v != 9.81 || err != nil {
+		t.Error("Wrong float", v, err)
+	}
+	if v, err := ps.Int("speed"); v != 42 || err != nil {
+		t.Error("Wrong int", v, err)
+	}
+	if v, err := ps.String("intro"); v != "First line\nSecond line" || err != nil {
+		t.Error("Wrong string", v, err)
+	}
+
+	spawn, err := ps.Class("spawn")
+	if err != nil || len(spawn) != 2 {
+		t.Fatal("Wrong class", spawn, err)
+	}
+	if v, err := spawn.Int("count"); v != 3 || err != nil {
+		t.Error("Wrong class member", v, err)
+	}
+	if v, err := spawn.File("sprite"); v != "testdata/sprites/imp.png" || err != nil {
+		t.Error("Wrong class member file", v, err)
+	}
+
+	if tile := m.Layers[0].TileAt(1, 1); tile.ID != 15 || tile.Tileset.Image.Path != "testdata/tiles.png" {
+		t.Error("Wrong tile", tile)
+	}
+}
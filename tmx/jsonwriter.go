@@ -0,0 +1,442 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+)
+
+// Writes the map in Tiled's JSON format (TMJ). Layers are taken as by Write, and tile layers written from their
+// GIDs. Layers in csv and XML encoding are written as arrays of GIDs, which is what the csv encoding
+// amounts to in JSON, all others in their encoding. Tilesets that have a Source are written as references only.
+func (m *Map) WriteJSON(w io.Writer) error {
+	jm := jsonMap{
+		Type:            "map",
+		Version:         jsonString(m.Version),
+		TiledVersion:    m.TiledVersion,
+		Class:           m.Class,
+		Orientation:     m.Orientation,
+		RenderOrder:     m.RenderOrder,
+		Width:           m.Width,
+		Height:          m.Height,
+		TileWidth:       m.TileWidth,
+		TileHeight:      m.TileHeight,
+		HexSideLength:   m.HexSideLength,
+		StaggerAxis:     m.StaggerAxis,
+		StaggerIndex:    m.StaggerIndex,
+		ParallaxOriginX: m.ParallaxOriginX,
+		ParallaxOriginY: m.ParallaxOriginY,
+		BackgroundColor: m.BackgroundColor,
+		NextLayerID:     m.NextLayerID,
+		NextObjectID:    m.NextObjectID,
+		Infinite:        m.Infinite,
+		Properties:      jsonProperties(m.Properties),
+		Tilesets:        make([]jsonTileset, len(m.Tilesets)),
+	}
+
+	for i := 0; i < len(m.Tilesets); i++ {
+		ts := &m.Tilesets[i]
+		if ts.Source != "" {
+			jm.Tilesets[i] = jsonTileset{FirstGID: ts.FirstGID, Source: ts.Source}
+			continue
+		}
+
+		jts, err := jsonFromTileset(ts)
+		if err != nil {
+			return err
+		}
+		jts.FirstGID = ts.FirstGID
+		jm.Tilesets[i] = *jts
+	}
+
+	var err error
+	if jm.Layers, err = jsonLayers(m, m.writtenLayers()); err != nil {
+		return err
+	}
+	return writeJSON(w, &jm)
+}
+
+// Writes the map as JSON to the named file, creating or truncating it.
+func (m *Map) WriteJSONFile(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Writes the tileset as an external JSON tileset (a TSJ file).
+func (ts *Tileset) WriteJSON(w io.Writer) error {
+	jts, err := jsonFromTileset(ts)
+	if err != nil {
+		return err
+	}
+	jts.Type = "tileset"
+	return writeJSON(w, jts)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	e := json.NewEncoder(w)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", " ")
+	return e.Encode(v)
+}
+
+func jsonFromTileset(ts *Tileset) (*jsonTileset, error) {
+	jts := &jsonTileset{
+		Name:             ts.Name,
+		Class:            ts.Class,
+		TileWidth:        ts.TileWidth,
+		TileHeight:       ts.TileHeight,
+		Spacing:          ts.Spacing,
+		Margin:           ts.Margin,
+		TileCount:        ts.Tilecount,
+		Columns:          ts.Columns,
+		Image:            ts.Image.Source,
+		ImageWidth:       ts.Image.Width,
+		ImageHeight:      ts.Image.Height,
+		TransparentColor: jsonColor(ts.Image.Trans),
+		Properties:       jsonProperties(ts.Properties),
+	}
+	if ts.ObjectAlignment != "unspecified" {
+		jts.ObjectAlignment = ts.ObjectAlignment
+	}
+	if ts.TileRenderSize != "tile" {
+		jts.TileRenderSize = ts.TileRenderSize
+	}
+	if ts.FillMode != "stretch" {
+		jts.FillMode = ts.FillMode
+	}
+	if ts.TileOffset != (TileOffset{}) {
+		offset := ts.TileOffset
+		jts.TileOffset = &offset
+	}
+	if g := ts.Grid; g.Width != 0 || g.Height != 0 || g.Orientation != "" && g.Orientation != "orthogonal" {
+		jts.Grid = &g
+	}
+	if tr := ts.Transformations; tr != (Transformations{}) {
+		jts.Transformations = &tr
+	}
+
+	for i := 0; i < len(ts.Tiles); i++ {
+		t := &ts.Tiles[i]
+		jt := jsonTile{
+			ID:          t.ID,
+			Type:        t.Type,
+			X:           t.X,
+			Y:           t.Y,
+			Width:       t.Width,
+			Height:      t.Height,
+			Properties:  jsonProperties(t.Properties),
+			Image:       t.Image.Source,
+			ImageWidth:  t.Image.Width,
+			ImageHeight: t.Image.Height,
+			Animation:   t.Animation,
+		}
+		if probability := t.Probability; probability != 1 {
+			jt.Probability = &probability
+		}
+		if t.ObjectGroup != nil {
+			jl, err := jsonFromObjectGroup(t.ObjectGroup)
+			if err != nil {
+				return nil, err
+			}
+			jt.ObjectGroup = &jl
+		}
+		jts.Tiles = append(jts.Tiles, jt)
+	}
+
+	for i := 0; i < len(ts.WangSets); i++ {
+		ws := &ts.WangSets[i]
+		tile := ws.Tile
+		jws := jsonWangSet{
+			Name:       ws.Name,
+			Class:      ws.Class,
+			Type:       ws.Type,
+			Tile:       &tile,
+			Properties: jsonProperties(ws.Properties),
+			Colors:     make([]jsonWangColor, len(ws.Colors)),
+			Tiles:      ws.Tiles,
+		}
+		for j := range ws.Colors {
+			c := &ws.Colors[j]
+			tile, probability := c.Tile, c.Probability
+			jws.Colors[j] = jsonWangColor{
+				Name:        c.Name,
+				Class:       c.Class,
+				Color:       c.Color,
+				Tile:        &tile,
+				Probability: &probability,
+				Properties:  jsonProperties(c.Properties),
+			}
+		}
+		jts.WangSets = append(jts.WangSets, jws)
+	}
+	return jts, nil
+}
+
+// TMX files store the transparent color without the leading '#'.
+func jsonColor(trans string) string {
+	if trans == "" {
+		return ""
+	}
+	return "#" + trans
+}
+
+func jsonLayers(m *Map, nodes []LayerNode) ([]jsonLayer, error) {
+	layers := make([]jsonLayer, 0, len(nodes))
+	for i := 0; i < len(nodes); i++ {
+		n := &nodes[i]
+
+		var jl jsonLayer
+		var err error
+		switch {
+		case n.Layer != nil:
+			jl, err = jsonFromLayer(m, n.Layer)
+		case n.ObjectGroup != nil:
+			jl, err = jsonFromObjectGroup(n.ObjectGroup)
+		case n.ImageLayer != nil:
+			l := n.ImageLayer
+			jl = jsonFromBase("imagelayer", &l.LayerBase)
+			jl.Image = l.Image.Source
+			jl.ImageWidth = l.Image.Width
+			jl.ImageHeight = l.Image.Height
+			jl.TransparentColor = jsonColor(l.Image.Trans)
+			jl.RepeatX, jl.RepeatY = l.RepeatX, l.RepeatY
+		case n.Group != nil:
+			jl = jsonFromBase("group", &n.Group.LayerBase)
+			jl.Layers, err = jsonLayers(m, n.Group.Children)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, jl)
+	}
+	return layers, nil
+}
+
+func jsonFromBase(typ string, b *LayerBase) jsonLayer {
+	opacity, visible := b.Opacity, b.Visible
+	jl := jsonLayer{
+		Type:       typ,
+		ID:         b.ID,
+		Name:       b.Name,
+		Class:      b.Class,
+		Locked:     b.Locked,
+		Opacity:    &opacity,
+		Visible:    &visible,
+		OffsetX:    b.OffsetX,
+		OffsetY:    b.OffsetY,
+		TintColor:  b.TintColor,
+		Properties: jsonProperties(b.Properties),
+	}
+	if b.ParallaxX != 1 {
+		parallaxX := b.ParallaxX
+		jl.ParallaxX = &parallaxX
+	}
+	if b.ParallaxY != 1 {
+		parallaxY := b.ParallaxY
+		jl.ParallaxY = &parallaxY
+	}
+	return jl
+}
+
+func jsonFromLayer(m *Map, l *Layer) (jsonLayer, error) {
+	jl := jsonFromBase("tilelayer", &l.LayerBase)
+	jl.Width, jl.Height = l.Width, l.Height
+	if jl.Width == 0 && jl.Height == 0 {
+		jl.Width, jl.Height = m.Width, m.Height
+	}
+	if l.Data.Encoding != "csv" && l.Data.Encoding != "" {
+		jl.Encoding, jl.Compression = l.Data.Encoding, l.Data.Compression
+	}
+
+	var err error
+	if !m.Infinite {
+		jl.Data, err = jsonFromGIDs(&l.Data, l.GIDs)
+		return jl, err
+	}
+
+	for i := 0; i < len(l.Data.Chunks); i++ {
+		c := &l.Data.Chunks[i]
+		jc := jsonChunk{X: c.X, Y: c.Y, Width: c.Width, Height: c.Height}
+		if jc.Data, err = jsonFromGIDs(&l.Data, c.GIDs); err != nil {
+			return jl, err
+		}
+		jl.Chunks = append(jl.Chunks, jc)
+	}
+	return jl, nil
+}
+
+// Encodes GIDs as an array, or as a string in the base64 and custom encodings, depending on d.
+func jsonFromGIDs(d *Data, gids []GID) (json.RawMessage, error) {
+	switch d.Encoding {
+	case "base64":
+		s, err := encodeBase64(gids, d.Compression)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(s)
+	case "csv", "":
+		return json.Marshal(gids)
+	}
+
+	b, err := encodeCustom(gids, d.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(b))
+}
+
+func jsonFromObjectGroup(g *ObjectGroup) (jsonLayer, error) {
+	jl := jsonFromBase("objectgroup", &g.LayerBase)
+	jl.Color = g.Color
+	for i := 0; i < len(g.Objects); i++ {
+		jo, err := jsonFromObject(&g.Objects[i])
+		if err != nil {
+			return jl, err
+		}
+		jl.Objects = append(jl.Objects, jo)
+	}
+	return jl, nil
+}
+
+func jsonFromObject(o *Object) (jsonObject, error) {
+	visible := o.Visible
+	jo := jsonObject{
+		ID:         o.ID,
+		Name:       o.Name,
+		Type:       o.Type,
+		X:          o.X,
+		Y:          o.Y,
+		Width:      o.Width,
+		Height:     o.Height,
+		Rotation:   o.Rotation,
+		GID:        int64(o.GID),
+		Visible:    &visible,
+		Template:   o.Template,
+		Ellipse:    o.Ellipse != nil,
+		Point:      o.Point != nil,
+		Properties: jsonProperties(o.Properties),
+	}
+
+	var err error
+	if len(o.Polygons) > 0 {
+		if jo.Polygon, err = o.Polygons[0].Decode(); err != nil {
+			return jo, err
+		}
+	}
+	if len(o.PolyLines) > 0 {
+		if jo.Polyline, err = o.PolyLines[0].Decode(); err != nil {
+			return jo, err
+		}
+	}
+
+	if t := o.Text; t != nil {
+		jo.Text = &jsonText{
+			Text:      t.Text,
+			Wrap:      t.Wrap,
+			Bold:      t.Bold,
+			Italic:    t.Italic,
+			Underline: t.Underline,
+			Strikeout: t.Strikeout,
+		}
+		// Defaults are omitted, as Tiled does.
+		if t.FontFamily != "sans-serif" {
+			jo.Text.FontFamily = t.FontFamily
+		}
+		if t.PixelSize != 16 {
+			jo.Text.PixelSize = t.PixelSize
+		}
+		if t.Color != "#000000" {
+			jo.Text.Color = t.Color
+		}
+		if !t.Kerning {
+			jo.Text.Kerning = &t.Kerning
+		}
+		if t.HAlign != "left" {
+			jo.Text.HAlign = t.HAlign
+		}
+		if t.VAlign != "top" {
+			jo.Text.VAlign = t.VAlign
+		}
+	}
+	return jo, nil
+}
+
+func jsonProperties(ps Properties) jsonProps {
+	if len(ps) == 0 {
+		return nil
+	}
+
+	jps := make(jsonProps, len(ps))
+	for i := 0; i < len(ps); i++ {
+		p := &ps[i]
+		jps[i] = jsonProperty{Name: p.Name, Type: p.Type, PropertyType: p.PropertyType, Value: jsonFromValue(p)}
+		if jps[i].Type == "" {
+			jps[i].Type = "string"
+		}
+	}
+	return jps
+}
+
+// Converts a property value into its typed JSON form: numbers, booleans, and objects for class values.
+// Values that do not parse as their type are written as strings.
+func jsonFromValue(p *Property) json.RawMessage {
+	switch p.Type {
+	case "int", "float", "object":
+		if _, err := strconv.ParseFloat(p.Value, 64); err == nil && json.Valid([]byte(p.Value)) {
+			return json.RawMessage(p.Value)
+		}
+		if p.Value == "" {
+			return json.RawMessage("0")
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(p.Value); err == nil {
+			return json.RawMessage(strconv.FormatBool(b))
+		}
+		if p.Value == "" {
+			return json.RawMessage("false")
+		}
+	case "class":
+		members := make(map[string]json.RawMessage, len(p.Properties))
+		for i := 0; i < len(p.Properties); i++ {
+			members[p.Properties[i].Name] = jsonFromValue(&p.Properties[i])
+		}
+		v, _ := json.Marshal(members)
+		return v
+	}
+
+	v, _ := json.Marshal(p.Value)
+	return v
+}
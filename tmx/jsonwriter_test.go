@@ -0,0 +1,189 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// Writes the map as JSON and reads it back, as if it was the file name.
+func rewriteJSON(t *testing.T, m *Map, name string) *Map {
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatal(name, err)
+	}
+
+	m2, err := new(Loader).read(&buf, name)
+	if err != nil {
+		t.Fatal(name, err, buf.String())
+	}
+	return m2
+}
+
+func TestWriteJSONRoundTrip(t *testing.T) {
+	var names []string
+	for _, pattern := range []string{"testdata/*.tmx", "testdata/*.tmj"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, matches...)
+	}
+
+	for _, name := range names {
+		m, err := ReadFile(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		compareMaps(t, name, m, rewriteJSON(t, m, name))
+	}
+}
+
+func TestWriteJSONEncodings(t *testing.T) {
+	m, err := ReadFile("testdata/csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodings := []struct{ encoding, compression string }{
+		{"csv", ""}, {"base64", ""}, {"base64", "zlib"}, {"base64", "gzip"}, {"base64", "zstd"}, {"", ""},
+	}
+
+	for _, enc := range encodings {
+		m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = enc.encoding, enc.compression
+
+		m2 := rewriteJSON(t, m, "testdata/csv.tmx")
+		if enc.encoding == "base64" && (m2.Layers[0].Data.Encoding != "base64" || m2.Layers[0].Data.Compression != enc.compression) {
+			t.Error("Encoding not written", enc)
+		}
+
+		gids, err := m2.decodeLayer(&m2.Layers[0])
+		if err != nil {
+			t.Fatal(enc, err)
+		}
+		if !equalGIDs(gids, layer0Data) {
+			t.Error("Wrong data written with", enc)
+		}
+	}
+}
+
+func TestWriteJSONSchema(t *testing.T) {
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var v struct {
+		Type   string `json:"type"`
+		Layers []struct {
+			Type   string            `json:"type"`
+			Name   string            `json:"name"`
+			Data   []GID             `json:"data"`
+			Layers []json.RawMessage `json:"layers"`
+		} `json:"layers"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	if v.Type != "map" || len(v.Layers) != len(m.LayerTree) {
+		t.Fatal("Wrong map written", buf.String())
+	}
+	for i, jl := range v.Layers {
+		n := &m.LayerTree[i]
+		var typ string
+		switch {
+		case n.Layer != nil:
+			typ = "tilelayer"
+			if len(jl.Data) != len(n.Layer.GIDs) {
+				t.Error("Wrong data written for layer", jl.Name)
+			}
+		case n.ObjectGroup != nil:
+			typ = "objectgroup"
+		case n.ImageLayer != nil:
+			typ = "imagelayer"
+		case n.Group != nil:
+			typ = "group"
+			if len(jl.Layers) != len(n.Group.Children) {
+				t.Error("Wrong children written for group", jl.Name)
+			}
+		}
+		if jl.Type != typ || jl.Name != n.Base().Name {
+			t.Error("Layer", i, "written as", jl.Type, jl.Name)
+		}
+	}
+}
+
+func TestWriteTilesetJSON(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/animated.tsx")
+
+	var buf bytes.Buffer
+	if err := ts.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ts2, err := ReadTileset(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts2.Name != ts.Name || ts2.Tilecount != ts.Tilecount || len(ts2.Tiles) != len(ts.Tiles) {
+		t.Fatal("Tileset differs", ts2)
+	}
+	for i := range ts.Tiles {
+		if len(ts2.Tiles[i].Animation) != len(ts.Tiles[i].Animation) {
+			t.Error("Animation of tile", ts.Tiles[i].ID, "differs")
+		}
+	}
+}
+
+func TestWriteJSONFlatLayers(t *testing.T) {
+	m := madeInGo()
+	m2 := rewriteJSON(t, m, "")
+	compareMaps(t, "made in Go", m, m2)
+	if len(m2.LayerTree) != 2 {
+		t.Fatal("Wrong layer tree written", m2.LayerTree)
+	}
+	if b := m2.LayerTree[0].Base(); !b.Visible || b.Opacity != 1 || !m2.ObjectGroups[0].Objects[0].Visible {
+		t.Error("Layers or objects written invisible")
+	}
+
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Layers = append(m.Layers, Layer{LayerBase: NewLayerBase("Extra"), Width: 2, Height: 2, GIDs: []GID{1, 1, 1, 1}})
+	m.Layers[1].Name = "Mist"
+
+	m2 = rewriteJSON(t, m, "testdata/group.tmj")
+	if len(m2.Layers) != 4 || m2.Layers[3].Name != "Extra" || m2.LayerByPath("Background/Sky/Mist") == nil {
+		t.Error("Layers of the slices not written")
+	}
+}
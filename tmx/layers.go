@@ -0,0 +1,380 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Attributes shared by all kinds of layers. The zero LayerBase is that of an invisible, transparent layer that
+// does not scroll; layers made in Go start from NewLayerBase.
+type LayerBase struct {
+	ID         ID         `xml:"id,attr"`
+	Name       string     `xml:"name,attr"`
+	Opacity    float32    `xml:"opacity,attr"`
+	Visible    bool       `xml:"visible,attr"`
+	Class      string     `xml:"class,attr"`
+	Locked     bool       `xml:"locked,attr"` // Whether the layer is locked for editing in Tiled.
+	OffsetX    float64    `xml:"offsetx,attr"`
+	OffsetY    float64    `xml:"offsety,attr"`
+	ParallaxX  float64    `xml:"parallaxx,attr"` // How fast the layer scrolls along with the camera; see ParallaxOffset.
+	ParallaxY  float64    `xml:"parallaxy,attr"`
+	TintColor  string     `xml:"tintcolor,attr"`
+	Properties Properties `xml:"properties>property"`
+	Parent     *Group     `xml:"-"` // The group this layer is nested in, nil for top-level layers.
+}
+
+// Returns the attributes of a layer with the given name, with the defaults of TMX files: visible, opaque and
+// scrolling along with the map.
+func NewLayerBase(name string) LayerBase {
+	b := LayerBase{Name: name}
+	b.defaults()
+	return b
+}
+
+// Sets the defaults of attributes Tiled omits when they have their default value.
+func (b *LayerBase) defaults() {
+	b.Opacity, b.Visible = 1, true
+	b.ParallaxX, b.ParallaxY = 1, 1
+}
+
+// A group layer, holding other layers.
+type Group struct {
+	LayerBase
+	Children []LayerNode `xml:",any"`
+}
+
+func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type group Group // Has no UnmarshalXML method, avoiding recursion.
+	v := (*group)(g)
+	v.LayerBase.defaults()
+	return d.DecodeElement(v, &start)
+}
+
+// A layer showing a single image, typically a backdrop.
+type ImageLayer struct {
+	LayerBase
+	Image   Image `xml:"image"`
+	RepeatX bool  `xml:"repeatx,attr"` // Whether the image is repeated along the X axis.
+	RepeatY bool  `xml:"repeaty,attr"` // Whether the image is repeated along the Y axis.
+}
+
+func (l *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type imageLayer ImageLayer // Has no UnmarshalXML method, avoiding recursion.
+	v := (*imageLayer)(l)
+	v.LayerBase.defaults()
+	return d.DecodeElement(v, &start)
+}
+
+// An entry of the layer tree. Exactly one of the fields is set.
+type LayerNode struct {
+	Layer       *Layer
+	ObjectGroup *ObjectGroup
+	ImageLayer  *ImageLayer
+	Group       *Group
+}
+
+func (n *LayerNode) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "layer":
+		n.Layer = new(Layer)
+		return d.DecodeElement(n.Layer, &start)
+	case "objectgroup":
+		n.ObjectGroup = new(ObjectGroup)
+		return d.DecodeElement(n.ObjectGroup, &start)
+	case "imagelayer":
+		n.ImageLayer = new(ImageLayer)
+		return d.DecodeElement(n.ImageLayer, &start)
+	case "group":
+		n.Group = new(Group)
+		return d.DecodeElement(n.Group, &start)
+	}
+	return d.Skip() // Not a layer; left empty and dropped by flattenLayers.
+}
+
+// Returns the attributes common to all layers.
+func (n *LayerNode) Base() *LayerBase {
+	switch {
+	case n.Layer != nil:
+		return &n.Layer.LayerBase
+	case n.ObjectGroup != nil:
+		return &n.ObjectGroup.LayerBase
+	case n.ImageLayer != nil:
+		return &n.ImageLayer.LayerBase
+	case n.Group != nil:
+		return &n.Group.LayerBase
+	}
+	return nil
+}
+
+// Drops non-layer entries from the tree, links layers to their parents and collects
+// tile, object and image layers into Map.Layers, Map.ObjectGroups and Map.ImageLayers.
+// The tree is then made to point into those slices, so that both views share the same layers.
+func (m *Map) flattenLayers() {
+	var layers, objectGroups, imageLayers int
+	m.walkLayers(func(n *LayerNode) {
+		switch {
+		case n.Layer != nil:
+			layers++
+		case n.ObjectGroup != nil:
+			objectGroups++
+		case n.ImageLayer != nil:
+			imageLayers++
+		}
+	})
+
+	m.Layers = make([]Layer, 0, layers)
+	m.ObjectGroups = make([]ObjectGroup, 0, objectGroups)
+	m.ImageLayers = make([]ImageLayer, 0, imageLayers)
+
+	var flatten func(nodes []LayerNode, parent *Group) []LayerNode
+	flatten = func(nodes []LayerNode, parent *Group) []LayerNode {
+		kept := nodes[:0]
+		for _, n := range nodes {
+			switch {
+			case n.Layer != nil:
+				n.Layer.Parent = parent
+				m.Layers = append(m.Layers, *n.Layer)
+				n.Layer = &m.Layers[len(m.Layers)-1]
+			case n.ObjectGroup != nil:
+				n.ObjectGroup.Parent = parent
+				m.ObjectGroups = append(m.ObjectGroups, *n.ObjectGroup)
+				n.ObjectGroup = &m.ObjectGroups[len(m.ObjectGroups)-1]
+			case n.ImageLayer != nil:
+				n.ImageLayer.Parent = parent
+				m.ImageLayers = append(m.ImageLayers, *n.ImageLayer)
+				n.ImageLayer = &m.ImageLayers[len(m.ImageLayers)-1]
+			case n.Group != nil:
+				n.Group.Parent = parent
+				n.Group.Children = flatten(n.Group.Children, n.Group)
+			default:
+				continue
+			}
+			kept = append(kept, n)
+		}
+		return kept
+	}
+	m.LayerTree = flatten(m.LayerTree, nil)
+}
+
+// Returns the layers to write: the groups and order of LayerTree, with the layers themselves taken from Layers,
+// ObjectGroups and ImageLayers in document order, so that maps made or changed through those slices are written
+// as they are. Layers of the slices past those in the tree follow at the top level, which is where all layers
+// of a map without a tree go; layers of the tree past those in the slices are left out.
+func (m *Map) writtenLayers() []LayerNode {
+	var layers, objectGroups, imageLayers int
+
+	var rebuild func(nodes []LayerNode) []LayerNode
+	rebuild = func(nodes []LayerNode) []LayerNode {
+		kept := make([]LayerNode, 0, len(nodes))
+		for _, n := range nodes {
+			switch {
+			case n.Layer != nil:
+				if layers == len(m.Layers) {
+					continue
+				}
+				n.Layer = &m.Layers[layers]
+				layers++
+			case n.ObjectGroup != nil:
+				if objectGroups == len(m.ObjectGroups) {
+					continue
+				}
+				n.ObjectGroup = &m.ObjectGroups[objectGroups]
+				objectGroups++
+			case n.ImageLayer != nil:
+				if imageLayers == len(m.ImageLayers) {
+					continue
+				}
+				n.ImageLayer = &m.ImageLayers[imageLayers]
+				imageLayers++
+			case n.Group != nil:
+				g := *n.Group
+				g.Children = rebuild(g.Children)
+				n.Group = &g
+			default:
+				continue
+			}
+			kept = append(kept, n)
+		}
+		return kept
+	}
+	tree := rebuild(m.LayerTree)
+
+	for ; layers < len(m.Layers); layers++ {
+		tree = append(tree, LayerNode{Layer: &m.Layers[layers]})
+	}
+	for ; objectGroups < len(m.ObjectGroups); objectGroups++ {
+		tree = append(tree, LayerNode{ObjectGroup: &m.ObjectGroups[objectGroups]})
+	}
+	for ; imageLayers < len(m.ImageLayers); imageLayers++ {
+		tree = append(tree, LayerNode{ImageLayer: &m.ImageLayers[imageLayers]})
+	}
+	return tree
+}
+
+// Calls f for every node of the layer tree, parents before their children.
+func (m *Map) walkLayers(f func(n *LayerNode)) {
+	var walk func(nodes []LayerNode)
+	walk = func(nodes []LayerNode) {
+		for i := 0; i < len(nodes); i++ {
+			f(&nodes[i])
+			if nodes[i].Group != nil {
+				walk(nodes[i].Group.Children)
+			}
+		}
+	}
+	walk(m.LayerTree)
+}
+
+// Returns the layer with the given ID, or nil if there is none.
+func (m *Map) LayerByID(id ID) *LayerNode {
+	var found *LayerNode
+	m.walkLayers(func(n *LayerNode) {
+		if found == nil && n.Base().ID == id {
+			found = n
+		}
+	})
+	return found
+}
+
+// Returns the layer found by following a slash-separated path of layer names from the top of the tree,
+// such as "Background/Clouds", or nil if there is none. The first matching name is taken at each level.
+func (m *Map) LayerByPath(path string) *LayerNode {
+	nodes := m.LayerTree
+	names := strings.Split(path, "/")
+	for i, name := range names {
+		var next *LayerNode
+		for j := 0; j < len(nodes); j++ {
+			if nodes[j].Base().Name == name {
+				next = &nodes[j]
+				break
+			}
+		}
+
+		if next == nil {
+			return nil
+		}
+		if i == len(names)-1 {
+			return next
+		}
+		if next.Group == nil {
+			return nil
+		}
+		nodes = next.Group.Children
+	}
+	return nil
+}
+
+// Opacity of the layer combined with that of all groups it is nested in.
+func (b *LayerBase) EffectiveOpacity() float32 {
+	opacity := b.Opacity
+	for g := b.Parent; g != nil; g = g.Parent {
+		opacity *= g.Opacity
+	}
+	return opacity
+}
+
+// Reports whether the layer and all groups it is nested in are visible.
+func (b *LayerBase) EffectiveVisible() bool {
+	visible := b.Visible
+	for g := b.Parent; g != nil; g = g.Parent {
+		visible = visible && g.Visible
+	}
+	return visible
+}
+
+// Offset of the layer, in pixels, added to the offsets of all groups it is nested in.
+func (b *LayerBase) EffectiveOffset() (x, y float64) {
+	x, y = b.OffsetX, b.OffsetY
+	for g := b.Parent; g != nil; g = g.Parent {
+		x += g.OffsetX
+		y += g.OffsetY
+	}
+	return x, y
+}
+
+// Parallax factors of the layer multiplied by those of all groups it is nested in.
+func (b *LayerBase) EffectiveParallax() (x, y float64) {
+	x, y = b.ParallaxX, b.ParallaxY
+	for g := b.Parent; g != nil; g = g.Parent {
+		x *= g.ParallaxX
+		y *= g.ParallaxY
+	}
+	return x, y
+}
+
+// Offset of the layer, in pixels, when the camera is at (cameraX, cameraY) in map coordinates: EffectiveOffset,
+// plus the distance of the camera from the map's parallax origin scaled by 1 minus EffectiveParallax.
+// Layers with a parallax factor of 1 stay at EffectiveOffset; those with a factor of 0 move along with the camera.
+// Tiled takes the center of the view as the camera position.
+func (b *LayerBase) ParallaxOffset(m *Map, cameraX, cameraY float64) (x, y float64) {
+	x, y = b.EffectiveOffset()
+	px, py := b.EffectiveParallax()
+	x += (cameraX - m.ParallaxOriginX) * (1 - px)
+	y += (cameraY - m.ParallaxOriginY) * (1 - py)
+	return x, y
+}
+
+// Tint color of the layer multiplied by the tint colors of all groups it is nested in.
+// Layers without a (valid) tint color count as white.
+func (b *LayerBase) EffectiveTint() color.NRGBA {
+	tint := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	multiply := func(s string) {
+		c, err := parseColor(s)
+		if s == "" || err != nil {
+			return
+		}
+		tint.R = uint8(uint16(tint.R) * uint16(c.R) / 0xff)
+		tint.G = uint8(uint16(tint.G) * uint16(c.G) / 0xff)
+		tint.B = uint8(uint16(tint.B) * uint16(c.B) / 0xff)
+		tint.A = uint8(uint16(tint.A) * uint16(c.A) / 0xff)
+	}
+
+	multiply(b.TintColor)
+	for g := b.Parent; g != nil; g = g.Parent {
+		multiply(g.TintColor)
+	}
+	return tint
+}
+
+// Parses colors in the #AARRGGBB or #RRGGBB forms used by Tiled; the # is optional.
+func parseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 && len(s) != 8 {
+		return color.NRGBA{}, InvalidColor
+	}
+
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, InvalidColor
+	}
+
+	c := color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+	if len(s) == 8 {
+		c.A = uint8(v >> 24)
+	}
+	return c, nil
+}
@@ -0,0 +1,144 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestLayerTree(t *testing.T) {
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	m.walkLayers(func(n *LayerNode) {
+		names = append(names, n.Base().Name)
+	})
+	want := []string{"Ground", "Background", "Backdrop", "Markers", "Sky", "Clouds", "Foreground", "Objects"}
+	if len(names) != len(want) {
+		t.Fatal("Wrong layer tree", names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatal("Wrong layer order", names)
+		}
+	}
+
+	if len(m.Layers) != 3 || m.Layers[1].Name != "Clouds" || len(m.ObjectGroups) != 2 {
+		t.Fatal("Wrong flattened layers")
+	}
+
+	clouds := m.LayerByPath("Background/Sky/Clouds")
+	if clouds == nil || clouds.Layer != &m.Layers[1] {
+		t.Fatal("Layer not found by path")
+	}
+	if m.LayerByID(5) != clouds {
+		t.Error("Layer not found by ID")
+	}
+	if m.LayerByPath("Background/Clouds") != nil || m.LayerByPath("Ground/Clouds") != nil {
+		t.Error("Found a layer by a wrong path")
+	}
+
+	l := clouds.Layer
+	if l.TileAt(1, 1).ID != 5 {
+		t.Error("Layer in a group not decoded")
+	}
+	if o := l.EffectiveOpacity(); o < 0.199 || o > 0.201 {
+		t.Error("Wrong effective opacity", o)
+	}
+	if l.EffectiveVisible() {
+		t.Error("Layer in a hidden group is visible")
+	}
+	if !m.LayerByPath("Background/Markers").Base().EffectiveVisible() {
+		t.Error("Layer in a visible group is hidden")
+	}
+	if x, y := l.EffectiveOffset(); x != 11 || y != -3 {
+		t.Error("Wrong effective offset", x, y)
+	}
+	if c := l.EffectiveTint(); c != (color.NRGBA{0xff, 0x80, 0x80, 0x80}) {
+		t.Error("Wrong effective tint", c)
+	}
+	if l.Class != "weather" || !l.Locked {
+		t.Error("Wrong class or locked flag", l.Class, l.Locked)
+	}
+}
+
+func TestParallax(t *testing.T) {
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clouds := m.LayerByPath("Background/Sky/Clouds").Base()
+	if x, y := clouds.EffectiveParallax(); x != 0.25 || y != 0.75 {
+		t.Error("Wrong effective parallax", x, y)
+	}
+
+	tests := []struct {
+		b                *LayerBase
+		cameraX, cameraY float64
+		x, y             float64
+	}{
+		{clouds, 16, 8, 11, -3},    // At the parallax origin, only offsets apply.
+		{clouds, 116, 58, 86, 9.5}, // 100*(1-0.25), 50*(1-0.75)
+		{m.LayerByPath("Background/Backdrop").Base(), 116, 58, 62, -1},
+		{m.LayerByPath("Ground").Base(), 116, 58, 0, 0},
+	}
+	for _, test := range tests {
+		if x, y := test.b.ParallaxOffset(m, test.cameraX, test.cameraY); x != test.x || y != test.y {
+			t.Error(test.b.Name, "wrong parallax offset", x, y)
+		}
+	}
+}
+
+func TestImageLayer(t *testing.T) {
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := m.LayerByPath("Background/Backdrop")
+	if n == nil || n.ImageLayer == nil || n.ImageLayer != &m.ImageLayers[0] {
+		t.Fatal("Image layer not found")
+	}
+
+	l := n.ImageLayer
+	if l.ID != 8 || l.Image.Source != "tiles.png" || l.Image.Path != "testdata/tiles.png" || l.Image.Width != 112 {
+		t.Error("Wrong image", l.ID, l.Image)
+	}
+	if !l.RepeatX || l.RepeatY {
+		t.Error("Wrong repeat flags", l.RepeatX, l.RepeatY)
+	}
+	if l.ParallaxX != 0.5 || l.ParallaxY != 1 {
+		t.Error("Wrong parallax factors", l.ParallaxX, l.ParallaxY)
+	}
+	if x, y := l.EffectiveOffset(); x != 12 || y != -1 {
+		t.Error("Wrong effective offset", x, y)
+	}
+	if len(l.Properties) != 1 || l.Properties[0].Value != "slow" {
+		t.Error("Wrong properties", l.Properties)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Limits on the resources a map may use, to read maps from untrusted sources. Zero means no limit.
+// Limits are checked before layers are decoded, so that a map exceeding them costs no more memory than its file.
+// The size of the file itself is not limited; use an io.LimitedReader or http.MaxBytesReader for that.
+type Limits struct {
+	MaxWidth      int   // Of the map, its layers and chunks, in tiles.
+	MaxHeight     int   // Of the map, its layers and chunks, in tiles.
+	MaxLayerBytes int64 // Size of the decoded data of a layer, 4 bytes per cell, which also bounds the bytes decompressed for it.
+	MaxLayers     int   // Number of layers of all kinds, groups included.
+	MaxObjects    int   // Number of objects in all object groups.
+	MaxDepth      int   // Nesting of external files: tilesets and templates of a map are at depth 1, tilesets of templates at 2.
+}
+
+var LimitExceeded = errors.New("tmx: limit exceeded")
+
+// The error returned when a map exceeds one of its Loader's Limits. It matches LimitExceeded with errors.Is.
+type LimitError struct {
+	Limit string // The name of the field of Limits.
+	Value int64  // The value found in the map; for MaxLayerBytes, the least number of bytes needed.
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("tmx: %s exceeded: %d > %d", e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return LimitExceeded
+}
+
+func checkLimit(limit string, v, max int64) error {
+	if max > 0 && v > max {
+		return &LimitError{limit, v, max}
+	}
+	return nil
+}
+
+func (lim *Limits) checkSize(width, height int) error {
+	if err := checkLimit("MaxWidth", int64(width), int64(lim.MaxWidth)); err != nil {
+		return err
+	}
+	return checkLimit("MaxHeight", int64(height), int64(lim.MaxHeight))
+}
+
+// Returns the number of cells of a width x height area, and false if that is negative or too large to decode.
+func cells(width, height int) (int64, bool) {
+	if width < 0 || height < 0 {
+		return 0, false
+	}
+	if height != 0 && int64(width) > math.MaxInt64/4/int64(height) {
+		return 0, false
+	}
+	return int64(width) * int64(height), true
+}
+
+// Checks the map against the limits, once its layers and tilesets are loaded but before they are decoded.
+func (lim *Limits) check(m *Map) error {
+	if err := lim.checkSize(m.Width, m.Height); err != nil {
+		return err
+	}
+
+	layers := 0
+	m.walkLayers(func(*LayerNode) { layers++ })
+	if err := checkLimit("MaxLayers", int64(layers), int64(lim.MaxLayers)); err != nil {
+		return err
+	}
+
+	objects := 0
+	for i := 0; i < len(m.ObjectGroups); i++ {
+		objects += len(m.ObjectGroups[i].Objects)
+	}
+	if err := checkLimit("MaxObjects", int64(objects), int64(lim.MaxObjects)); err != nil {
+		return err
+	}
+
+	for i := 0; i < len(m.Layers); i++ {
+		if err := lim.checkLayer(m, &m.Layers[i]); err != nil {
+			return m.Layers[i].locate(err, i, nil)
+		}
+	}
+	return nil
+}
+
+func (lim *Limits) checkLayer(m *Map, l *Layer) error {
+	width, height := l.Width, l.Height
+	if width == 0 && height == 0 {
+		width, height = m.Width, m.Height
+	}
+
+	n, ok := int64(0), true
+	if m.Infinite {
+		for j := 0; j < len(l.Data.Chunks) && ok; j++ {
+			c := &l.Data.Chunks[j]
+			if err := lim.checkSize(c.Width, c.Height); err != nil {
+				return err
+			}
+			var k int64
+			k, ok = cells(c.Width, c.Height)
+			n += k
+			ok = ok && n <= math.MaxInt64/4
+		}
+	} else {
+		if err := lim.checkSize(width, height); err != nil {
+			return err
+		}
+		n, ok = cells(width, height)
+	}
+	if !ok {
+		return InvalidDecodedDataLen
+	}
+
+	return checkLimit("MaxLayerBytes", 4*n, lim.MaxLayerBytes)
+}
+
+func (lim *Limits) checkDepth(depth int) error {
+	return checkLimit("MaxDepth", int64(depth), int64(lim.MaxDepth))
+}
+
+// A reader that fails once its context is done, so that reading a map from a slow source can be cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,479 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// A Loader reads maps along with the files they reference: external tilesets, object templates and images.
+// Every reference is resolved relative to the file it appears in, and opened through FS.
+// A Loader may be used by several goroutines at once, as long as its fields are not changed meanwhile.
+//
+// On the host file system, absolute references and references leading out of the directory of the map are
+// opened as they are, so that a map can make the loader read any file the process can. Maps from untrusted
+// sources should be read with Confine set, or from an FS that keeps them in, such as os.DirFS.
+type Loader struct {
+	FS fs.FS // Files are opened from FS. Nil means the host file system, where names are as for os.Open.
+
+	// Keeps the files opened on the host file system to the directory of the file read, the map or tileset, and
+	// those below it, as their names tell: symbolic links are followed. Other files fail to open with OutsideRoot,
+	// and so do they with Map.Open. Ignored if FS is set.
+	Confine bool
+
+	// The number of layers (or chunks, in infinite maps) decoded concurrently. Zero or one decodes them one by one,
+	// a negative number uses GOMAXPROCS goroutines.
+	Workers int
+
+	Limits Limits // Limits on the resources used by a map, for maps from untrusted sources.
+
+	root string // The directory files are confined to, if Confine is set.
+}
+
+var OutsideRoot = errors.New("tmx: file outside of the directory of the map")
+
+// Reads the map with the given name (a slash-separated path in l.FS).
+func (l *Loader) ReadFile(name string) (*Map, error) {
+	return l.ReadFileContext(context.Background(), name)
+}
+
+// Like ReadFile, but gives up with the error of ctx once it is done.
+func (l *Loader) ReadFileContext(ctx context.Context, name string) (*Map, error) {
+	if l.FS == nil {
+		name = filepath.ToSlash(name)
+	}
+	l = l.confined(name)
+
+	f, err := l.fsys().Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return l.readContext(ctx, f, name)
+}
+
+// Reads a map, in TMX or JSON format, from r. name is the path of the map in l.FS, against which the files it
+// references are resolved, or "" if it is unknown. Gives up with the error of ctx once it is done.
+func (l *Loader) ReadContext(ctx context.Context, r io.Reader, name string) (*Map, error) {
+	return l.confined(name).readContext(ctx, r, name)
+}
+
+// Reads an external tileset with the given name. FirstGID is left zero.
+func (l *Loader) ReadTilesetFile(name string) (*Tileset, error) {
+	if l.FS == nil {
+		name = filepath.ToSlash(name)
+	}
+	return l.confined(name).readTileset(context.Background(), name, 0)
+}
+
+// Opens a file of the map's file system, typically Image.Path.
+func (m *Map) Open(name string) (fs.File, error) {
+	if m.fsys == nil {
+		return hostFS{}.Open(name)
+	}
+	return m.fsys.Open(name)
+}
+
+// hostFS opens files from the host file system without the restrictions of os.DirFS, so that
+// absolute paths and paths leading outside of the current directory keep working as they did with os.Open.
+// Unless root is empty, only the files in root and below it are opened.
+type hostFS struct {
+	root string
+}
+
+func (h hostFS) Open(name string) (fs.File, error) {
+	if h.root != "" && !inDir(h.root, name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: OutsideRoot}
+	}
+	return os.Open(filepath.FromSlash(name))
+}
+
+// Reports whether the slash-separated path name is dir or below it, as far as the names tell.
+func inDir(dir, name string) bool {
+	name = path.Clean(name)
+	if dir == "." {
+		return name != ".." && !strings.HasPrefix(name, "../") && !path.IsAbs(name) && !filepath.IsAbs(filepath.FromSlash(name))
+	}
+	return name == dir || strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/")
+}
+
+func isHostFS(fsys fs.FS) bool {
+	_, ok := fsys.(hostFS)
+	return fsys == nil || ok
+}
+
+func (l *Loader) fsys() fs.FS {
+	if l.FS == nil {
+		return hostFS{l.root}
+	}
+	return l.FS
+}
+
+// Returns the loader to read the file name with, confined to its directory if l.Confine is set.
+func (l *Loader) confined(name string) *Loader {
+	if l.FS != nil || !l.Confine {
+		return l
+	}
+	c := *l
+	c.root = path.Dir(path.Clean(name))
+	return &c
+}
+
+// Resolves ref, as it appears in the file named base.
+func (l *Loader) resolve(base, ref string) string {
+	return resolvePath(l.FS == nil, base, ref)
+}
+
+// Absolute paths are only honoured on the host file system.
+func resolvePath(host bool, base, ref string) string {
+	if host && (path.IsAbs(ref) || filepath.IsAbs(ref)) {
+		return filepath.ToSlash(ref)
+	}
+	return path.Join(path.Dir(base), ref)
+}
+
+func (l *Loader) read(r io.Reader, name string) (*Map, error) {
+	return l.readContext(context.Background(), r, name)
+}
+
+// name is the path of the map in l.FS, or "" when it is unknown.
+// The format, TMX or JSON, is told by the content.
+func (l *Loader) readContext(ctx context.Context, r io.Reader, name string) (*Map, error) {
+	m, err := l.load(ctx, r, name)
+	if err != nil {
+		return nil, inFile(err, name)
+	}
+	return m, nil
+}
+
+func (l *Loader) load(ctx context.Context, r io.Reader, name string) (*Map, error) {
+	br := bufio.NewReader(&contextReader{ctx, r})
+
+	var m *Map
+	if isJSON(br) {
+		var err error
+		if m, err = readJSONMap(br); err != nil {
+			return nil, err
+		}
+	} else {
+		m = new(Map)
+		if err := xml.NewDecoder(br).Decode(m); err != nil {
+			return nil, err
+		}
+	}
+	m.fsys = l.fsys()
+	m.flattenLayers()
+
+	if err := l.loadTilesets(ctx, m, name); err != nil {
+		return nil, err
+	}
+
+	if err := l.loadTemplates(ctx, m, name); err != nil {
+		return nil, err
+	}
+
+	if err := l.Limits.check(m); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(m.ImageLayers); i++ {
+		if img := &m.ImageLayers[i].Image; img.Source != "" {
+			img.Path = l.resolve(name, img.Source)
+		}
+	}
+
+	m.bindProperties(name)
+
+	err := m.decodeLayers(ctx, l.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(m.Layers); i++ {
+		layer := &m.Layers[i]
+
+		tileset, isEmpty, usesMultipleTilesets := getTileset(m, layer)
+		if usesMultipleTilesets {
+			continue
+		}
+		layer.Empty, layer.Tileset = isEmpty, tileset
+	}
+
+	return m, nil
+}
+
+// Replaces each tileset that refers to an external file with the contents of that file.
+// Source and FirstGID are kept as they appear in the map.
+func (l *Loader) loadTilesets(ctx context.Context, m *Map, name string) error {
+	for i := 0; i < len(m.Tilesets); i++ {
+		ts := &m.Tilesets[i]
+		if ts.Source == "" {
+			ts.file = name
+			l.resolveImages(ts)
+			continue
+		}
+
+		external, err := l.readTileset(ctx, l.resolve(name, ts.Source), 1)
+		if err != nil {
+			return err
+		}
+
+		external.FirstGID, external.Source = ts.FirstGID, ts.Source
+		*ts = *external
+	}
+	return nil
+}
+
+// Reads a tileset at the given depth of external files; see Limits.MaxDepth.
+func (l *Loader) readTileset(ctx context.Context, name string, depth int) (*Tileset, error) {
+	if err := l.Limits.checkDepth(depth); err != nil {
+		return nil, inFile(err, name)
+	}
+
+	f, err := l.fsys().Open(name)
+	if err != nil {
+		return nil, inFile(err, name)
+	}
+
+	defer f.Close()
+
+	ts, err := ReadTileset(&contextReader{ctx, f})
+	if err != nil {
+		return nil, inFile(err, name)
+	}
+
+	ts.file = name
+	l.resolveImages(ts)
+	return ts, nil
+}
+
+func (l *Loader) resolveImages(ts *Tileset) {
+	if ts.Image.Source != "" {
+		ts.Image.Path = l.resolve(ts.file, ts.Image.Source)
+	}
+	for i := 0; i < len(ts.Tiles); i++ {
+		if img := &ts.Tiles[i].Image; img.Source != "" {
+			img.Path = l.resolve(ts.file, img.Source)
+		}
+	}
+}
+
+// An object template, as stored in a TX (or TJ) file.
+type template struct {
+	Tileset *Tileset `xml:"tileset"`
+	Object  Object   `xml:"object"`
+
+	file     string
+	firstGID GID // The FirstGID of Tileset in the map being loaded, once it was added there or found.
+}
+
+// Applies the templates of all objects that have one. Tile objects of a template may add tilesets to the map.
+// Each template file is read once per map, and adds its tileset once.
+func (l *Loader) loadTemplates(ctx context.Context, m *Map, name string) error {
+	templates := make(map[string]*template)
+	for i := 0; i < len(m.ObjectGroups); i++ {
+		group := &m.ObjectGroups[i]
+		for j := 0; j < len(group.Objects); j++ {
+			o := &group.Objects[j]
+			if o.Template == "" {
+				continue
+			}
+
+			t, err := l.readTemplate(ctx, templates, l.resolve(name, o.Template), 1)
+			if err != nil {
+				return err
+			}
+
+			if err := l.applyTemplate(m, name, o, t); err != nil {
+				e := located(err)
+				e.Layer, e.LayerIndex = group.Name, i
+				e.Object, e.ObjectID, e.ObjectIndex = o.Name, o.ID, j
+				return e
+			}
+		}
+	}
+	return nil
+}
+
+// Reads the template with the given name, unless it is in templates already, and adds it there.
+func (l *Loader) readTemplate(ctx context.Context, templates map[string]*template, name string, depth int) (*template, error) {
+	if t, ok := templates[name]; ok {
+		return t, nil
+	}
+
+	if err := l.Limits.checkDepth(depth); err != nil {
+		return nil, inFile(err, name)
+	}
+
+	f, err := l.fsys().Open(name)
+	if err != nil {
+		return nil, inFile(err, name)
+	}
+
+	defer f.Close()
+
+	br := bufio.NewReader(&contextReader{ctx, f})
+
+	t := new(template)
+	if isJSON(br) {
+		t, err = readJSONTemplate(br)
+	} else {
+		err = xml.NewDecoder(br).Decode(t)
+	}
+	if err != nil {
+		return nil, inFile(err, name)
+	}
+	t.file = name
+	t.Object.Properties.bind(name, nil)
+
+	if t.Tileset != nil && t.Tileset.Source != "" {
+		ts, err := l.readTileset(ctx, l.resolve(name, t.Tileset.Source), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		ts.FirstGID, ts.Source = t.Tileset.FirstGID, t.Tileset.Source
+		t.Tileset = ts
+	} else if t.Tileset != nil {
+		t.Tileset.file = name
+		l.resolveImages(t.Tileset)
+	}
+
+	templates[name] = t
+	return t, nil
+}
+
+// Fills in the attributes o leaves unset from its template. Values are copied, so that objects sharing a template
+// share none of its memory.
+func (l *Loader) applyTemplate(m *Map, name string, o *Object, t *template) error {
+	to := &t.Object
+
+	if o.Name == "" {
+		o.Name = to.Name
+	}
+	if o.Type == "" {
+		o.Type = to.Type
+	}
+	if o.Width == 0 {
+		o.Width = to.Width
+	}
+	if o.Height == 0 {
+		o.Height = to.Height
+	}
+	if o.Rotation == 0 {
+		o.Rotation = to.Rotation
+	}
+	if o.Shape() == ShapeRectangle {
+		o.Ellipse, o.Point = to.Ellipse, to.Point
+		if to.Text != nil {
+			text := *to.Text
+			o.Text = &text
+		}
+	}
+	if len(o.Polygons) == 0 {
+		o.Polygons = append([]Polygon(nil), to.Polygons...)
+	}
+	if len(o.PolyLines) == 0 {
+		o.PolyLines = append([]PolyLine(nil), to.PolyLines...)
+	}
+
+	for _, p := range to.Properties {
+		found := false
+		for _, q := range o.Properties {
+			if q.Name == p.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			p.Properties = p.Properties.clone()
+			o.Properties = append(o.Properties, p)
+		}
+	}
+
+	if o.GID == 0 && to.GID != 0 {
+		if t.Tileset == nil {
+			return inFile(InvalidGID, t.file)
+		}
+		if t.firstGID == 0 {
+			t.firstGID = mapTileset(m, name, t.Tileset).FirstGID
+		}
+		gid := GID(to.GID)
+		o.GID = int(gid&^GIDFlip - t.Tileset.FirstGID + t.firstGID | gid&GIDFlip)
+	}
+
+	return nil
+}
+
+// Returns the map's tileset that is loaded from the same file as ts, adding ts to the map if there is none.
+// name is the path of the map, which the Source of an added tileset is made relative to. Tilesets embedded in
+// templates are always added, and stay embedded in the map.
+func mapTileset(m *Map, name string, ts *Tileset) *Tileset {
+	for i := 0; i < len(m.Tilesets); i++ {
+		if m.Tilesets[i].Source != "" && m.Tilesets[i].file == ts.file {
+			return &m.Tilesets[i]
+		}
+	}
+
+	next := GID(1)
+	for i := 0; i < len(m.Tilesets); i++ {
+		if last := m.Tilesets[i].FirstGID + GID(m.Tilesets[i].tileCount()); last > next {
+			next = last
+		}
+	}
+
+	added := *ts
+	added.FirstGID = next
+	if ts.Source != "" {
+		if rel, err := filepath.Rel(filepath.FromSlash(path.Dir(name)), filepath.FromSlash(ts.file)); err == nil {
+			added.Source = filepath.ToSlash(rel)
+		}
+	}
+	m.Tilesets = append(m.Tilesets, added)
+	return &m.Tilesets[len(m.Tilesets)-1]
+}
+
+// Number of tile IDs the tileset occupies.
+func (ts *Tileset) tileCount() int {
+	n := ts.Tilecount
+	if n == 0 && ts.TileWidth > 0 && ts.TileHeight > 0 {
+		rows := (ts.Image.Height - 2*ts.Margin + ts.Spacing) / (ts.TileHeight + ts.Spacing)
+		n = ts.columns() * rows
+	}
+	for i := 0; i < len(ts.Tiles); i++ {
+		if int(ts.Tiles[i].ID) >= n {
+			n = int(ts.Tiles[i].ID) + 1
+		}
+	}
+	return n
+}
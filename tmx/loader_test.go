@@ -0,0 +1,418 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoaderFS(t *testing.T) {
+	l := &Loader{FS: os.DirFS("testdata")}
+	m, err := l.ReadFile("external.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &m.Tilesets[0]
+	if ts.Name != "default" {
+		t.Error("Tileset not loaded from FS")
+	}
+	if ts.Image.Path != "tiles.png" {
+		t.Error("Image path not resolved against the tileset file:", ts.Image.Path)
+	}
+
+	f, err := m.Open(ts.Image.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+}
+
+func TestLoaderMemFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/level.tmx": {Data: []byte(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" source="../shared/set.tsx"/>
+ <layer name="L" width="1" height="1"><data encoding="csv">2</data></layer>
+</map>`)},
+		"shared/set.tsx":     {Data: []byte(`<tileset name="set" tilewidth="8" tileheight="8" tilecount="4"><image source="img/set.png" width="16" height="16"/></tileset>`)},
+		"shared/img/set.png": {Data: []byte("not really a png")},
+	}
+
+	m, err := (&Loader{FS: fsys}).ReadFile("maps/level.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Layers[0].TileAt(0, 0).ID != 1 {
+		t.Error("Wrong tile ID", m.Layers[0].TileAt(0, 0).ID)
+	}
+
+	f, err := m.Open(m.Tilesets[0].Image.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	b, err := io.ReadAll(f)
+	if err != nil || string(b) != "not really a png" {
+		t.Error("Wrong image contents", string(b), err)
+	}
+}
+
+func TestLoaderConfine(t *testing.T) {
+	dir := t.TempDir()
+	set := `<tileset name="set" tilewidth="8" tileheight="8" tilecount="4"/>`
+	level := `<map width="1" height="1" tilewidth="8" tileheight="8"><tileset firstgid="1" source="%s"/></map>`
+	files := map[string]string{
+		"shared/set.tsx":   set,
+		"maps/set.tsx":     set,
+		"maps/level.tmx":   fmt.Sprintf(level, "set.tsx"),
+		"maps/up.tmx":      fmt.Sprintf(level, "../shared/set.tsx"),
+		"maps/abs.tmx":     fmt.Sprintf(level, filepath.ToSlash(filepath.Join(dir, "shared/set.tsx"))),
+		"maps/sub/up.tmx":  fmt.Sprintf(level, "../set.tsx"),
+		"maps/sub/set.tsx": set,
+	}
+	for name, data := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		outside bool
+	}{{"maps/level.tmx", false}, {"maps/up.tmx", true}, {"maps/abs.tmx", true}, {"maps/sub/up.tmx", true}} {
+		name := filepath.Join(dir, test.name)
+		if _, err := new(Loader).ReadFile(name); err != nil {
+			t.Error(test.name, "not read without Confine:", err)
+		}
+		_, err := (&Loader{Confine: true}).ReadFile(name)
+		if test.outside != errors.Is(err, OutsideRoot) {
+			t.Error(test.name, "wrong error with Confine:", err)
+		}
+	}
+
+	m, err := (&Loader{Confine: true}).ReadFile("testdata/external.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.Open(m.Tilesets[0].Image.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if _, err := m.Open("testdata/../loader.go"); !errors.Is(err, OutsideRoot) {
+		t.Error("File outside of the directory of the map opened", err)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	m, err := ReadFile("testdata/template.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Tilesets) != 2 {
+		t.Fatal("Template tileset not added to the map")
+	}
+	if m.Tilesets[1].Source != "tilesets/default.tsx" {
+		t.Error("Template tileset source not relative to the map", m.Tilesets[1].Source)
+	}
+	if m.Tilesets[1].FirstGID != 29 {
+		t.Error("Wrong firstgid for the template tileset", m.Tilesets[1].FirstGID)
+	}
+
+	o := &m.ObjectGroups[0].Objects[0]
+	if o.Name != "chest" || o.Type != "container" || o.Width != 8 || o.X != 8 {
+		t.Error("Template not applied", o)
+	}
+	if o.GID != 29+14 {
+		t.Error("Template GID not remapped", o.GID)
+	}
+
+	tile, err := m.DecodeGID(GID(o.GID))
+	if err != nil || tile.Tileset != &m.Tilesets[1] || tile.ID != 14 {
+		t.Error("Template GID resolves to the wrong tile", tile, err)
+	}
+
+	props := map[string]string{}
+	for _, p := range o.Properties {
+		props[p.Name] = p.Value
+	}
+	if props["locked"] != "true" || props["loot"] != "gold" {
+		t.Error("Template properties not merged", props)
+	}
+}
+
+func TestTemplateCopies(t *testing.T) {
+	fsys := fstest.MapFS{
+		"map.tmx": {Data: []byte(`<map width="1" height="1" tilewidth="8" tileheight="8"><objectgroup name="Objects">
+ <object id="1" template="zone.tx"/><object id="2" template="zone.tx"/>
+ <object id="3" template="label.tx"/><object id="4" template="label.tx"/>
+</objectgroup></map>`)},
+		"zone.tx": {Data: []byte(`<template><object name="zone"><properties>
+ <property name="spawn" type="class" propertytype="Spawn"><properties><property name="count" type="int" value="3"/></properties></property>
+</properties><polygon points="0,0 8,0 8,8"/></object></template>`)},
+		"label.tx": {Data: []byte(`<template><object name="label"><text>Hi</text></object></template>`)},
+	}
+
+	l := &Loader{FS: fsys}
+	m, err := l.ReadFile("map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := l.ReadFile("map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objects := m.ObjectGroups[0].Objects
+	objects[0].Polygons[0].Points = "0,0"
+	objects[0].Properties[0].Properties[0].Value = "4"
+	objects[2].Text.Text = "Bye"
+
+	for _, o := range []*Object{&objects[1], &m2.ObjectGroups[0].Objects[0]} {
+		if o.Polygons[0].Points != "0,0 8,0 8,8" || o.Properties[0].Properties[0].Value != "3" {
+			t.Error("Template values shared between objects", o.Polygons, o.Properties)
+		}
+	}
+	for _, o := range []*Object{&objects[3], &m2.ObjectGroups[0].Objects[2]} {
+		if o.Text.Text != "Hi" {
+			t.Error("Template text shared between objects", o.Text.Text)
+		}
+	}
+
+	if p := &m2.ObjectGroups[0].Objects[0].Properties[0].Properties[0]; p.m != m2 {
+		t.Error("Template property bound to the wrong map")
+	}
+}
+
+func TestTemplateEmbeddedTileset(t *testing.T) {
+	fsys := fstest.MapFS{
+		"maps/map.tmx": {Data: []byte(`<map width="1" height="1" tilewidth="8" tileheight="8">
+ <tileset firstgid="1" name="ground" tilewidth="8" tileheight="8" tilecount="4"/>
+ <objectgroup name="Objects">
+  <object id="1" template="../templates/crate.tx"/><object id="2" template="../templates/crate.tx"/>
+ </objectgroup>
+</map>`)},
+		"templates/crate.tx": {Data: []byte(`<template>
+ <tileset firstgid="1" name="crates" tilewidth="8" tileheight="8" tilecount="2"><image source="crates.png" width="16" height="8"/></tileset>
+ <object name="crate" gid="2" width="8" height="8"/>
+</template>`)},
+	}
+
+	m, err := (&Loader{FS: fsys}).ReadFile("maps/map.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Tilesets) != 2 {
+		t.Fatal("Template tileset added more than once", len(m.Tilesets))
+	}
+	ts := &m.Tilesets[1]
+	if ts.Name != "crates" || ts.FirstGID != 5 || ts.Source != "" {
+		t.Error("Wrong template tileset added", ts.Name, ts.FirstGID, ts.Source)
+	}
+	if ts.Image.Path != "templates/crates.png" {
+		t.Error("Image path not resolved against the template file:", ts.Image.Path)
+	}
+	for _, o := range m.ObjectGroups[0].Objects {
+		if o.GID != 6 {
+			t.Error("Template GID not remapped", o.ID, o.GID)
+		}
+	}
+}
+
+func TestLoaderConcurrent(t *testing.T) {
+	l := &Loader{Limits: Limits{MaxDepth: 2}}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = l.ReadFile("testdata/template.tmx")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLoaderWorkers(t *testing.T) {
+	data := benchmarkMap(32, 16)
+	want, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInfinite, err := ReadFile("testdata/infinite.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{-1, 2, 3, 100} {
+		l := &Loader{Workers: workers}
+		m, err := l.read(bytes.NewReader(data), "")
+		if err != nil {
+			t.Fatal(workers, err)
+		}
+		mInfinite, err := l.ReadFile("testdata/infinite.tmx")
+		if err != nil {
+			t.Fatal(workers, err)
+		}
+
+		for _, maps := range [][2]*Map{{want, m}, {wantInfinite, mInfinite}} {
+			for i := range maps[0].Layers {
+				l, l2 := &maps[0].Layers[i], &maps[1].Layers[i]
+				if !equalGIDs(layerGIDs(l), layerGIDs(l2)) || l.Tileset != nil && l2.Tileset == nil {
+					t.Error(workers, "layer", l.Name, "differs")
+				}
+			}
+		}
+	}
+}
+
+func TestLoaderWorkersError(t *testing.T) {
+	// Layers 3 and 6 are broken in different ways, and the error must always be that of layer 3.
+	var b strings.Builder
+	b.WriteString(`<map width="2" height="1"><tileset firstgid="1" name="ts" tilewidth="8" tileheight="8"/>`)
+	for i := 0; i < 8; i++ {
+		data := "1,1"
+		switch i {
+		case 3:
+			data = "1,1,1"
+		case 6:
+			data = "1,x"
+		}
+		fmt.Fprintf(&b, `<layer name="l%d"><data encoding="csv">%s</data></layer>`, i, data)
+	}
+	b.WriteString(`</map>`)
+
+	for i := 0; i < 50; i++ {
+		l := &Loader{Workers: 4}
+		_, err := l.read(strings.NewReader(b.String()), "")
+		var e *Error
+		if !errors.Is(err, InvalidDecodedDataLen) || !errors.As(err, &e) || e.LayerIndex != 3 {
+			t.Fatal("Wrong error", err)
+		}
+	}
+}
+
+func TestLoaderLimits(t *testing.T) {
+	const bomb = `<map width="1048576" height="1048576"><layer name="l"><data encoding="csv">1</data></layer></map>`
+
+	tests := []struct {
+		limits Limits
+		name   string
+		data   string
+		limit  string
+	}{
+		{Limits{MaxWidth: 4096, MaxHeight: 4096}, "", bomb, "MaxWidth"},
+		{Limits{MaxLayerBytes: 1 << 20}, "", bomb, "MaxLayerBytes"},
+		{Limits{MaxHeight: 16}, "testdata/base64.tmx", "", "MaxHeight"},
+		{Limits{MaxLayerBytes: 4*32*32 - 1}, "testdata/base64.tmx", "", "MaxLayerBytes"},
+		{Limits{MaxLayerBytes: 4*3*16 - 1}, "testdata/infinite.tmx", "", "MaxLayerBytes"},
+		{Limits{MaxWidth: 3}, "testdata/infinite.tmx", "", "MaxWidth"},
+		{Limits{MaxLayers: 4}, "testdata/group.tmx", "", "MaxLayers"},
+		{Limits{MaxObjects: 3}, "testdata/objects.tmx", "", "MaxObjects"},
+		{Limits{MaxDepth: 1}, "testdata/template.tmx", "", "MaxDepth"},
+	}
+
+	for _, test := range tests {
+		l := &Loader{Limits: test.limits}
+
+		var err error
+		if test.name != "" {
+			_, err = l.ReadFile(test.name)
+		} else {
+			_, err = l.read(strings.NewReader(test.data), "")
+		}
+
+		var le *LimitError
+		if !errors.Is(err, LimitExceeded) || !errors.As(err, &le) || le.Limit != test.limit {
+			t.Error(test.name, test.limit, "wrong error", err)
+		}
+	}
+
+	// Maps within the limits load.
+	l := &Loader{Limits: Limits{MaxWidth: 32, MaxHeight: 32, MaxLayerBytes: 4 * 32 * 32, MaxLayers: 1}}
+	if _, err := l.ReadFile("testdata/base64.tmx"); err != nil {
+		t.Error(err)
+	}
+	l = &Loader{Limits: Limits{MaxDepth: 2}}
+	if _, err := l.ReadFile("testdata/template.tmx"); err != nil {
+		t.Error(err)
+	}
+
+	// Dimensions too large to decode fail even without limits.
+	if _, err := Read(strings.NewReader(`<map width="-1" height="1"><layer name="l"><data encoding="csv"></data></layer></map>`)); !errors.Is(err, InvalidDecodedDataLen) {
+		t.Error("Wrong error for negative dimensions", err)
+	}
+	if _, err := Read(strings.NewReader(strings.Replace(bomb, "1048576", "4611686018427387904", 2))); !errors.Is(err, InvalidDecodedDataLen) {
+		t.Error("Wrong error for huge dimensions", err)
+	}
+}
+
+func TestReadContext(t *testing.T) {
+	data, err := os.ReadFile("testdata/base64.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if _, err := ReadContext(ctx, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if _, err := ReadContext(ctx, bytes.NewReader(data)); !errors.Is(err, context.Canceled) {
+		t.Error("Wrong error for a cancelled read", err)
+	}
+	if _, err := new(Loader).ReadFileContext(ctx, "testdata/external.tmx"); !errors.Is(err, context.Canceled) {
+		t.Error("Wrong error for a cancelled read", err)
+	}
+
+	// Cancelled while decoding.
+	if err := runJobs(ctx, []func() error{func() error { return nil }}, 2); err != context.Canceled {
+		t.Error("Wrong error for cancelled jobs", err)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"math"
+)
+
+// The kind of shape of an object.
+type Shape int
+
+const (
+	ShapeRectangle Shape = iota
+	ShapeEllipse
+	ShapePoint
+	ShapePolygon
+	ShapePolyline
+	ShapeText
+	ShapeTile // A tile object, with Object.GID set.
+)
+
+var shapeNames = []string{"rectangle", "ellipse", "point", "polygon", "polyline", "text", "tile"}
+
+func (s Shape) String() string {
+	if s < 0 || int(s) >= len(shapeNames) {
+		return "unknown"
+	}
+	return shapeNames[s]
+}
+
+// Returns the kind of shape of the object. Objects without any shape child are rectangles.
+func (o *Object) Shape() Shape {
+	switch {
+	case o.GID != 0:
+		return ShapeTile
+	case o.Ellipse != nil:
+		return ShapeEllipse
+	case o.Point != nil:
+		return ShapePoint
+	case len(o.Polygons) > 0:
+		return ShapePolygon
+	case len(o.PolyLines) > 0:
+		return ShapePolyline
+	case o.Text != nil:
+		return ShapeText
+	}
+	return ShapeRectangle
+}
+
+// The text of a text object, along with how it is rendered.
+type Text struct {
+	Text       string `xml:",chardata"`
+	FontFamily string `xml:"fontfamily,attr"`
+	PixelSize  int    `xml:"pixelsize,attr"`
+	Wrap       bool   `xml:"wrap,attr"` // Whether lines wrap at the object's width.
+	Color      string `xml:"color,attr"`
+	Bold       bool   `xml:"bold,attr"`
+	Italic     bool   `xml:"italic,attr"`
+	Underline  bool   `xml:"underline,attr"`
+	Strikeout  bool   `xml:"strikeout,attr"`
+	Kerning    bool   `xml:"kerning,attr"`
+	HAlign     string `xml:"halign,attr"` // One of left, center, right or justify.
+	VAlign     string `xml:"valign,attr"` // One of top, center or bottom.
+}
+
+func (t *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type text Text // Has no UnmarshalXML method, avoiding recursion.
+	v := (*text)(t)
+
+	// Defaults, omitted by Tiled.
+	v.FontFamily = "sans-serif"
+	v.PixelSize = 16
+	v.Color = "#000000"
+	v.Kerning = true
+	v.HAlign = "left"
+	v.VAlign = "top"
+
+	return d.DecodeElement(v, &start)
+}
+
+// Returns the object with the given ID, or nil if there is none.
+func (m *Map) ObjectByID(id ID) *Object {
+	for i := 0; i < len(m.ObjectGroups); i++ {
+		g := &m.ObjectGroups[i]
+		for j := 0; j < len(g.Objects); j++ {
+			if g.Objects[j].ID == id {
+				return &g.Objects[j]
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the vertices of a polygon or polyline object, relative to the object's position.
+// Returns nil for objects of other shapes.
+func (o *Object) Points() ([]Point, error) {
+	var s string
+	switch {
+	case len(o.Polygons) > 0:
+		s = o.Polygons[0].Points
+	case len(o.PolyLines) > 0:
+		s = o.PolyLines[0].Points
+	default:
+		return nil, nil
+	}
+
+	points, err := decodePoints(s)
+	if err != nil {
+		e := located(err)
+		e.Object, e.ObjectID = o.Name, o.ID
+		return nil, e
+	}
+	return points, nil
+}
+
+// Like Points, but with the object's rotation and position applied.
+func (o *Object) AbsolutePoints() ([]Point, error) {
+	points, err := o.Points()
+	for i := range points {
+		points[i] = o.Absolute(points[i])
+	}
+	return points, err
+}
+
+// Converts a point relative to the object into the coordinate space of the object's position:
+// it is rotated by Rotation around the object's origin, then moved by (X, Y).
+func (o *Object) Absolute(p Point) Point {
+	if o.Rotation != 0 {
+		sin, cos := math.Sincos(o.Rotation * math.Pi / 180)
+		p.X, p.Y = p.X*cos-p.Y*sin, p.X*sin+p.Y*cos
+	}
+	return Point{X: o.X + p.X, Y: o.Y + p.Y}
+}
@@ -0,0 +1,140 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestObjectShapes(t *testing.T) {
+	m, err := ReadFile("testdata/objects.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shapes := map[string]Shape{
+		"box":   ShapeRectangle,
+		"round": ShapeEllipse,
+		"spawn": ShapePoint,
+		"area":  ShapePolygon,
+		"path":  ShapePolyline,
+		"label": ShapeText,
+		"plain": ShapeText,
+		"crate": ShapeTile,
+	}
+
+	objects := m.ObjectGroups[0].Objects
+	if len(objects) != len(shapes) {
+		t.Fatal("Wrong number of objects", len(objects))
+	}
+	for i, o := range objects {
+		if o.ID != ID(i+1) {
+			t.Error("Wrong ID", o.ID, "for", o.Name)
+		}
+		if s := o.Shape(); s != shapes[o.Name] {
+			t.Error("Wrong shape", s, "for", o.Name)
+		}
+	}
+
+	if box := m.ObjectByID(1); box == nil || box.Name != "box" || box.Rotation != 45 {
+		t.Error("Wrong object for ID 1", box)
+	}
+	if m.ObjectByID(100) != nil {
+		t.Error("Found an object with a nonexistent ID")
+	}
+
+	label := m.ObjectByID(6).Text
+	if label.Text != "Hello, world" || label.FontFamily != "Serif" || label.PixelSize != 12 || !label.Wrap ||
+		label.Color != "#ff0000" || !label.Bold || label.Italic || !label.Kerning || label.HAlign != "center" || label.VAlign != "top" {
+		t.Error("Wrong text", label)
+	}
+
+	plain := m.ObjectByID(7).Text
+	if plain.Text != "Defaults" || plain.FontFamily != "sans-serif" || plain.PixelSize != 16 || plain.Wrap ||
+		plain.Color != "#000000" || !plain.Kerning || plain.HAlign != "left" || plain.VAlign != "top" {
+		t.Error("Wrong text defaults", plain)
+	}
+
+	if ShapePolyline.String() != "polyline" {
+		t.Error("Wrong shape name", ShapePolyline)
+	}
+}
+
+func TestObjectPoints(t *testing.T) {
+	o := Object{ID: 3, Name: "slope", X: 10, Y: 20, Rotation: 90, Polygons: []Polygon{{Points: " 0,0\t12.5,3.25\n\n -1e1,-0.5 "}}}
+
+	points, err := o.Points()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Point{{0, 0}, {12.5, 3.25}, {-10, -0.5}}
+	if len(points) != len(want) {
+		t.Fatal("Wrong points", points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Error("Wrong point", i, points[i])
+		}
+	}
+
+	abs, err := o.AbsolutePoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []Point{{10, 20}, {6.75, 32.5}, {10.5, 10}}
+	for i := range want {
+		if math.Abs(abs[i].X-want[i].X) > 1e-9 || math.Abs(abs[i].Y-want[i].Y) > 1e-9 {
+			t.Error("Wrong absolute point", i, abs[i])
+		}
+	}
+
+	for _, s := range []string{"", "0,0 1", "0,0 1,2,3", "0,0 a,1"} {
+		o.Polygons[0].Points = s
+		_, err := o.Points()
+		if !errors.Is(err, InvalidPointsField) || !strings.Contains(err.Error(), `"slope"`) {
+			t.Errorf("Wrong error for %q: %v", s, err)
+		}
+	}
+
+	if points, err := (&Object{}).Points(); points != nil || err != nil {
+		t.Error("Rectangle has points", points, err)
+	}
+}
+
+func TestObjectClass(t *testing.T) {
+	m, err := Read(strings.NewReader(`<map width="1" height="1"><objectgroup name="o">
+ <object id="1" type="door"/><object id="2" class="door"/>
+</objectgroup></map>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range m.ObjectGroups[0].Objects {
+		if o.Type != "door" {
+			t.Error("Wrong class of object", o.ID, o.Type)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+)
+
+var (
+	PropertyNotFound = errors.New("tmx: property not found")
+)
+
+// Custom properties of a map, tileset, layer, object, etc. Properties are looked up by name,
+// and the typed accessors parse the value of the first property with that name.
+type Properties []Property
+
+func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type property Property // Has no UnmarshalXML method, avoiding recursion.
+	v := struct {
+		*property
+		Text string `xml:",chardata"`
+	}{property: (*property)(p)}
+
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+
+	for _, a := range start.Attr {
+		if a.Name.Local == "value" {
+			return nil
+		}
+	}
+	if p.Type != "class" {
+		p.Value = v.Text
+	}
+	return nil
+}
+
+// Returns the first property with the given name, or nil if there is none.
+func (ps Properties) Get(name string) *Property {
+	for i := 0; i < len(ps); i++ {
+		if ps[i].Name == name {
+			return &ps[i]
+		}
+	}
+	return nil
+}
+
+func (ps Properties) get(name string) (*Property, error) {
+	if p := ps.Get(name); p != nil {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%w: %q", PropertyNotFound, name)
+}
+
+func (ps Properties) String(name string) (string, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return "", err
+	}
+	return p.Value, nil
+}
+
+func (ps Properties) Int(name string) (int, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.Atoi(p.Value)
+	if err != nil {
+		return 0, fmt.Errorf("tmx: property %q: %w", name, err)
+	}
+	return v, nil
+}
+
+func (ps Properties) Float(name string) (float64, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseFloat(p.Value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("tmx: property %q: %w", name, err)
+	}
+	return v, nil
+}
+
+func (ps Properties) Bool(name string) (bool, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return false, err
+	}
+
+	v, err := strconv.ParseBool(p.Value)
+	if err != nil {
+		return false, fmt.Errorf("tmx: property %q: %w", name, err)
+	}
+	return v, nil
+}
+
+// Parses a color property, stored as #AARRGGBB (or #RRGGBB).
+func (ps Properties) Color(name string) (color.NRGBA, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return color.NRGBA{}, err
+	}
+
+	c, err := parseColor(p.Value)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("tmx: property %q: %w", name, err)
+	}
+	return c, nil
+}
+
+// Returns the path a file property refers to, resolved against the file the property was read from,
+// so that it can be passed to Map.Open. An empty value yields an empty path.
+func (ps Properties) File(name string) (string, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return "", err
+	}
+
+	if p.Value == "" {
+		return "", nil
+	}
+	return resolvePath(p.m == nil || isHostFS(p.m.fsys), p.file, p.Value), nil
+}
+
+// Returns the object an object property refers to, or nil if the property refers to none (its value is 0).
+func (ps Properties) Object(name string) (*Object, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(p.Value, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("tmx: property %q: %w", name, err)
+	}
+	if id == 0 {
+		return nil, nil
+	}
+
+	if p.m == nil {
+		return nil, fmt.Errorf("tmx: property %q: not part of a map", name)
+	}
+	o := p.m.ObjectByID(ID(id))
+	if o == nil {
+		return nil, fmt.Errorf("tmx: property %q: no object with ID %d", name, id)
+	}
+	return o, nil
+}
+
+// Returns the members of a class property.
+func (ps Properties) Class(name string) (Properties, error) {
+	p, err := ps.get(name)
+	if err != nil {
+		return nil, err
+	}
+	return p.Properties, nil
+}
+
+// Returns a copy of the properties, members of class properties included.
+func (ps Properties) clone() Properties {
+	if ps == nil {
+		return nil
+	}
+	c := make(Properties, len(ps))
+	for i := 0; i < len(ps); i++ {
+		c[i] = ps[i]
+		c[i].Properties = ps[i].Properties.clone()
+	}
+	return c
+}
+
+// Records where properties came from, so that file and object properties can be resolved.
+// Properties already bound to a file, such as those merged from templates, keep it.
+func (ps Properties) bind(file string, m *Map) {
+	for i := 0; i < len(ps); i++ {
+		p := &ps[i]
+		if p.file == "" {
+			p.file = file
+		}
+		p.m = m
+		p.Properties.bind(p.file, m)
+	}
+}
+
+// Binds all properties of the map, which was read from the file name.
+func (m *Map) bindProperties(name string) {
+	m.Properties.bind(name, m)
+
+	for i := 0; i < len(m.Tilesets); i++ {
+		ts := &m.Tilesets[i]
+		ts.Properties.bind(ts.file, m)
+		for j := 0; j < len(ts.WangSets); j++ {
+			ws := &ts.WangSets[j]
+			ws.Properties.bind(ts.file, m)
+			for k := 0; k < len(ws.Colors); k++ {
+				ws.Colors[k].Properties.bind(ts.file, m)
+			}
+		}
+		for j := 0; j < len(ts.Tiles); j++ {
+			ts.Tiles[j].Properties.bind(ts.file, m)
+			if g := ts.Tiles[j].ObjectGroup; g != nil {
+				g.Properties.bind(ts.file, m)
+				for k := 0; k < len(g.Objects); k++ {
+					g.Objects[k].Properties.bind(ts.file, m)
+				}
+			}
+		}
+	}
+
+	m.walkLayers(func(n *LayerNode) {
+		n.Base().Properties.bind(name, m)
+		if g := n.ObjectGroup; g != nil {
+			for k := 0; k < len(g.Objects); k++ {
+				g.Objects[k].Properties.bind(name, m)
+			}
+		}
+	})
+}
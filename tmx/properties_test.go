@@ -0,0 +1,96 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestTypedProperties(t *testing.T) {
+	m, err := ReadFile("testdata/properties.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := m.Properties
+	if v, err := ps.String("title"); v != "Properties" || err != nil {
+		t.Error("Wrong string", v, err)
+	}
+	if v, err := ps.Int("speed"); v != 42 || err != nil {
+		t.Error("Wrong int", v, err)
+	}
+	if v, err := ps.Float("gravity"); v != 9.81 || err != nil {
+		t.Error("Wrong float", v, err)
+	}
+	if v, err := ps.Bool("dark"); !v || err != nil {
+		t.Error("Wrong bool", v, err)
+	}
+	if v, err := ps.Color("fog"); v != (color.NRGBA{0xff, 0, 0, 0x80}) || err != nil {
+		t.Error("Wrong color", v, err)
+	}
+	if v, err := ps.File("music"); v != "music/theme.ogg" || err != nil {
+		t.Error("Wrong file", v, err)
+	}
+	if v, err := ps.String("intro"); v != "First line\nSecond line" || err != nil {
+		t.Errorf("Wrong multi-line string %q %v", v, err)
+	}
+	if o, err := ps.Object("boss"); o == nil || o.Name != "ogre" || err != nil {
+		t.Error("Wrong object", o, err)
+	}
+	if o, err := ps.Object("nobody"); o != nil || err != nil {
+		t.Error("Wrong empty object", o, err)
+	}
+	if p := ps.Get("speed"); p == nil || p.Type != "int" {
+		t.Error("Wrong type", p)
+	}
+
+	spawn, err := ps.Class("spawn")
+	if err != nil || ps.Get("spawn").PropertyType != "Spawn" {
+		t.Fatal("Wrong class", err)
+	}
+	if v, err := spawn.Int("count"); v != 3 || err != nil {
+		t.Error("Wrong class member", v, err)
+	}
+	if v, err := spawn.File("sprite"); v != "testdata/sprites/imp.png" || err != nil {
+		t.Error("Wrong class member file", v, err)
+	}
+
+	if v, err := m.Tilesets[0].Properties.File("palette"); v != "testdata/tilesets/palettes/day.pal" || err != nil {
+		t.Error("File not resolved against the tileset", v, err)
+	}
+	if o, err := m.ObjectByID(1).Properties.Object("target"); o != m.ObjectByID(2) || err != nil {
+		t.Error("Wrong object property of an object", o, err)
+	}
+
+	if _, err := ps.Int("nosuchproperty"); !errors.Is(err, PropertyNotFound) {
+		t.Error("Wrong error for missing property", err)
+	}
+	if _, err := ps.Int("title"); err == nil {
+		t.Error("No error for malformed int")
+	}
+	if _, err := ps.Color("speed"); !errors.Is(err, InvalidColor) {
+		t.Error("Wrong error for malformed color", err)
+	}
+}
@@ -0,0 +1,159 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+)
+
+type compression struct {
+	decompress func(io.Reader) (io.Reader, error)
+	compress   func(io.Writer) (io.WriteCloser, error)
+}
+
+type encoding struct {
+	decode func(data []byte) ([]GID, error)
+	encode func(gids []GID) ([]byte, error)
+}
+
+var (
+	registryMu   sync.RWMutex
+	compressions = make(map[string]compression)
+	encodings    = make(map[string]encoding)
+)
+
+func init() {
+	RegisterCompression("gzip", decompressGzip, func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil })
+	RegisterCompression("zlib", decompressZlib, func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil })
+	RegisterCompression("zstd", decompressZstd, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	})
+}
+
+// The built-in decompressors are reused: closing one puts it back into its pool. Unlike those of the standard
+// library, the gzip and zlib readers of klauspost/compress do not allocate for each block they decode.
+var gzipReaders, zlibReaders, zstdReaders sync.Pool
+
+type gzipReader struct{ gzip.Reader }
+
+func (r *gzipReader) Close() error {
+	gzipReaders.Put(r)
+	return nil
+}
+
+func decompressGzip(r io.Reader) (io.Reader, error) {
+	zr, _ := gzipReaders.Get().(*gzipReader)
+	if zr == nil {
+		zr = new(gzipReader)
+	}
+	if err := zr.Reset(r); err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+type zlibReader struct{ io.ReadCloser }
+
+func (r *zlibReader) Close() error {
+	r.ReadCloser.Close()
+	zlibReaders.Put(r)
+	return nil
+}
+
+func decompressZlib(r io.Reader) (io.Reader, error) {
+	if zr, _ := zlibReaders.Get().(*zlibReader); zr != nil {
+		if err := zr.ReadCloser.(zlib.Resetter).Reset(r, nil); err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+
+	rc, err := zlib.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &zlibReader{rc}, nil
+}
+
+type zstdReader struct{ *zstd.Decoder }
+
+func (r *zstdReader) Close() error {
+	zstdReaders.Put(r)
+	return nil
+}
+
+func decompressZstd(r io.Reader) (io.Reader, error) {
+	if zr, _ := zstdReaders.Get().(*zstdReader); zr != nil {
+		if err := zr.Reset(r); err != nil {
+			return nil, err
+		}
+		return zr, nil
+	}
+
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return &zstdReader{d}, nil
+}
+
+// Registers a compression method for base64 encoded layer data, by the name used in the compression attribute
+// of its data element. decompress is used when reading maps; if the reader it returns is an io.Closer, it is
+// closed once the data has been read. compress is used when writing maps, and may be nil if that is not needed.
+// Registering a name again replaces the previous methods, including the built-in gzip, zlib and zstd.
+// It is safe to call RegisterCompression concurrently with reading and writing maps.
+func RegisterCompression(name string, decompress func(io.Reader) (io.Reader, error), compress func(io.Writer) (io.WriteCloser, error)) {
+	registryMu.Lock()
+	compressions[name] = compression{decompress, compress}
+	registryMu.Unlock()
+}
+
+// Registers an encoding of layer data, by the name used in the encoding attribute of its data element.
+// decode is given the text of the element, with surrounding white space removed, and returns the GIDs of all
+// tiles of the layer (or chunk) it holds. encode does the opposite when writing maps, and may be nil if that is
+// not needed. The compression attribute is left to the encoding; only base64 data is decompressed by the package.
+// The built-in encodings csv, base64 and XML (the empty name) cannot be replaced.
+func RegisterEncoding(name string, decode func(data []byte) ([]GID, error), encode func(gids []GID) ([]byte, error)) {
+	registryMu.Lock()
+	encodings[name] = encoding{decode, encode}
+	registryMu.Unlock()
+}
+
+func lookupCompression(name string) (compression, bool) {
+	registryMu.RLock()
+	c, ok := compressions[name]
+	registryMu.RUnlock()
+	return c, ok
+}
+
+func lookupEncoding(name string) (encoding, bool) {
+	registryMu.RLock()
+	e, ok := encodings[name]
+	registryMu.RUnlock()
+	return e, ok
+}
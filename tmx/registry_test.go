@@ -0,0 +1,131 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Inverts all bits of the data, in both directions.
+type invert struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (v *invert) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] = ^p[i]
+	}
+	return n, err
+}
+
+func (v *invert) Write(p []byte) (int, error) {
+	q := make([]byte, len(p))
+	for i := range p {
+		q[i] = ^p[i]
+	}
+	return v.w.Write(q)
+}
+
+func (v *invert) Close() error {
+	return nil
+}
+
+func init() {
+	RegisterCompression("invert",
+		func(r io.Reader) (io.Reader, error) { return &invert{r: r}, nil },
+		func(w io.Writer) (io.WriteCloser, error) { return &invert{w: w}, nil },
+	)
+
+	// GIDs in hexadecimal, separated by spaces.
+	RegisterEncoding("hex",
+		func(data []byte) ([]GID, error) {
+			fields := strings.Fields(string(data))
+			gids := make([]GID, len(fields))
+			for i, f := range fields {
+				gid, err := strconv.ParseUint(f, 16, 32)
+				if err != nil {
+					return nil, err
+				}
+				gids[i] = GID(gid)
+			}
+			return gids, nil
+		},
+		func(gids []GID) ([]byte, error) {
+			var b []byte
+			for i, gid := range gids {
+				if i > 0 {
+					b = append(b, ' ')
+				}
+				b = strconv.AppendUint(b, uint64(gid), 16)
+			}
+			return b, nil
+		},
+	)
+
+	RegisterCompression("readonly", func(r io.Reader) (io.Reader, error) { return r, nil }, nil)
+}
+
+func TestRegistry(t *testing.T) {
+	m, err := ReadFile("testdata/csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodings := []struct{ encoding, compression string }{{"base64", "invert"}, {"hex", ""}}
+	for _, enc := range encodings {
+		m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = enc.encoding, enc.compression
+
+		for _, m2 := range []*Map{rewrite(t, m, "testdata/csv.tmx"), rewriteJSON(t, m, "testdata/csv.tmx")} {
+			if m2.Layers[0].Data.Encoding != enc.encoding || m2.Layers[0].Data.Compression != enc.compression {
+				t.Error("Encoding not written", enc)
+			}
+
+			gids, err := m2.decodeLayer(&m2.Layers[0])
+			if err != nil {
+				t.Fatal(enc, err)
+			}
+			if !equalGIDs(gids, layer0Data) {
+				t.Error("Wrong data written with", enc)
+			}
+		}
+	}
+
+	m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = "base64", "readonly"
+	if err := m.Write(new(bytes.Buffer)); err != UnknownCompression {
+		t.Error("Wrong error for a compression that cannot be written", err)
+	}
+
+	m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = "nosuchencoding", ""
+	if err := m.Write(new(bytes.Buffer)); err != UnknownEncoding {
+		t.Error("Wrong error for an unknown encoding", err)
+	}
+	if _, err := m.decodeLayer(&m.Layers[0]); err != UnknownEncoding {
+		t.Error("Wrong error for decoding an unknown encoding", err)
+	}
+}
@@ -0,0 +1,17 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="animated" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <image source="../tiles.png" width="112" height="16"/>
+ <tile id="6">
+  <animation>
+   <frame tileid="6" duration="100"/>
+   <frame tileid="7" duration="100"/>
+   <frame tileid="20" duration="200"/>
+  </animation>
+ </tile>
+ <tile id="21">
+  <animation>
+   <frame tileid="21" duration="250"/>
+   <frame tileid="22" duration="250"/>
+  </animation>
+ </tile>
+</tileset>
@@ -0,0 +1,10 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="collection" tilewidth="112" tileheight="16" tilecount="2" columns="0">
+ <grid orientation="orthogonal" width="1" height="1"/>
+ <tile id="0" x="16" y="8" width="8" height="8">
+  <image source="../tiles.png" width="112" height="16"/>
+ </tile>
+ <tile id="3" class="sky">
+  <image source="../tiles.png" width="112" height="16"/>
+ </tile>
+</tileset>
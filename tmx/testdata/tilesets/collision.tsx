@@ -0,0 +1,12 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="collision" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <image source="../tiles.png" width="112" height="16"/>
+ <tile id="0">
+  <objectgroup draworder="index" id="2">
+   <object id="1" x="0" y="0" width="4" height="2"/>
+   <object id="2" x="2" y="2">
+    <polygon points="0,0 4,0 4,4"/>
+   </object>
+  </objectgroup>
+ </tile>
+</tileset>
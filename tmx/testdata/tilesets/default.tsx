@@ -0,0 +1,4 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset name="default" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <image source="../tiles.png" width="112" height="16"/>
+</tileset>
@@ -0,0 +1,12 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="isometric" class="terrain" tilewidth="16" tileheight="8" spacing="2" margin="1" tilecount="6" columns="3" objectalignment="bottom" tilerendersize="grid" fillmode="preserve-aspect-fit">
+ <tileoffset x="0" y="4"/>
+ <grid orientation="isometric" width="32" height="16"/>
+ <transformations hflip="1" vflip="0" rotate="1" preferuntransformed="1"/>
+ <image source="isometric.png" width="54" height="20"/>
+ <tile id="4" type="water" probability="0.25">
+  <properties>
+   <property name="depth" type="int" value="3"/>
+  </properties>
+ </tile>
+</tileset>
@@ -0,0 +1,7 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="properties" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <properties>
+  <property name="palette" type="file" value="palettes/day.pal"/>
+ </properties>
+ <image source="../tiles.png" width="112" height="16"/>
+</tileset>
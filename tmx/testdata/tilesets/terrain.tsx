@@ -0,0 +1,21 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.4" tiledversion="1.4.3" name="terrain" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <image source="../tiles.png" width="112" height="16"/>
+ <terraintypes>
+  <terrain name="Grass" tile="0"/>
+  <terrain name="Sand" tile="1">
+   <properties>
+    <property name="speed" type="float" value="0.5"/>
+   </properties>
+  </terrain>
+ </terraintypes>
+ <tile id="0" terrain="0,0,0,0"/>
+ <tile id="1" terrain="1,1,1,1"/>
+ <tile id="2" terrain="1,1,0,0"/>
+ <tile id="5" terrain=",,,1"/>
+ <tile id="6">
+  <animation>
+   <frame tileid="6" duration="100"/>
+  </animation>
+ </tile>
+</tileset>
@@ -0,0 +1,21 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.4" tiledversion="1.4.3" name="wang-legacy" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <image source="../tiles.png" width="112" height="16"/>
+ <wangsets>
+  <wangset name="Ground" tile="0">
+   <wangcornercolor name="Grass" color="#00ff00" tile="0" probability="1"/>
+   <wangcornercolor name="Sand" color="#ffff00" tile="1" probability="0.5"/>
+   <wangtile tileid="0" wangid="0x10101010"/>
+   <wangtile tileid="1" wangid="0x20202020"/>
+   <wangtile tileid="2" wangid="0x20101020"/>
+   <wangtile tileid="3" wangid="0x20201010"/>
+   <wangtile tileid="4" wangid="0x20101010"/>
+  </wangset>
+  <wangset name="Paths" tile="-1">
+   <wangedgecolor name="Road" color="#808080" tile="-1" probability="1"/>
+   <wangcornercolor name="Grass" color="#00ff00" tile="-1" probability="1"/>
+   <wangtile tileid="14" wangid="0x11101110"/>
+   <wangtile tileid="15" wangid="0x10111011" hflip="1"/>
+  </wangset>
+ </wangsets>
+</tileset>
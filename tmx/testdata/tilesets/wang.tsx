@@ -0,0 +1,28 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" tiledversion="1.10.2" name="wang" tilewidth="8" tileheight="8" tilecount="28" columns="14">
+ <transformations hflip="1" vflip="1" rotate="0" preferuntransformed="0"/>
+ <image source="../tiles.png" width="112" height="16"/>
+ <wangsets>
+  <wangset name="Ground" type="corner" tile="0">
+   <properties>
+    <property name="layer" value="ground"/>
+   </properties>
+   <wangcolor name="Grass" color="#00ff00" tile="0" probability="1"/>
+   <wangcolor name="Sand" class="soft" color="#ffff00" tile="1" probability="0.5">
+    <properties>
+     <property name="speed" type="float" value="0.5"/>
+    </properties>
+   </wangcolor>
+   <wangtile tileid="0" wangid="0,1,0,1,0,1,0,1"/>
+   <wangtile tileid="1" wangid="0,2,0,2,0,2,0,2"/>
+   <wangtile tileid="2" wangid="0,2,0,1,0,1,0,2"/>
+   <wangtile tileid="3" wangid="0,1,0,1,0,2,0,2"/>
+   <wangtile tileid="4" wangid="0,1,0,1,0,1,0,2"/>
+  </wangset>
+  <wangset name="Roads" type="edge" tile="-1">
+   <wangcolor name="Road" color="#808080" tile="-1" probability="1"/>
+   <wangtile tileid="14" wangid="0,0,1,0,0,0,1,0"/>
+   <wangtile tileid="15" wangid="1,0,0,0,1,0,0,0"/>
+  </wangset>
+ </wangsets>
+</tileset>
@@ -0,0 +1,155 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"image"
+)
+
+// Offset, in pixels, at which the tiles of a tileset are drawn.
+type TileOffset struct {
+	X int `xml:"x,attr" json:"x"`
+	Y int `xml:"y,attr" json:"y"`
+}
+
+// The grid tiles are snapped to in tile objects and the terrain tools, for tilesets of isometric tiles.
+type Grid struct {
+	Orientation string `xml:"orientation,attr" json:"orientation"` // orthogonal (the default) or isometric.
+	Width       int    `xml:"width,attr" json:"width"`
+	Height      int    `xml:"height,attr" json:"height"`
+}
+
+// The ways tiles of a tileset may be transformed, by the terrain tools and when autotiling.
+type Transformations struct {
+	HFlip               bool `xml:"hflip,attr" json:"hflip"`
+	VFlip               bool `xml:"vflip,attr" json:"vflip"`
+	Rotate              bool `xml:"rotate,attr" json:"rotate"`
+	PreferUntransformed bool `xml:"preferuntransformed,attr" json:"preferuntransformed"` // Whether untransformed tiles are picked over transformed ones.
+}
+
+func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Attrs Tileset // Has no UnmarshalXML method, avoiding recursion. Exported, so that it is decoded when embedded.
+	v := struct {
+		*Attrs
+		Terrains []WangColor `xml:"terraintypes>terrain"` // Replaced by wang sets in Tiled 1.5.
+	}{Attrs: (*Attrs)(ts)}
+
+	ts.defaults()
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	ts.convertTerrains(v.Terrains)
+	return nil
+}
+
+// Sets the defaults of attributes Tiled omits when they have their default value.
+func (ts *Tileset) defaults() {
+	ts.ObjectAlignment, ts.TileRenderSize, ts.FillMode = "unspecified", "tile", "stretch"
+	ts.Grid.Orientation = "orthogonal"
+}
+
+func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tile Tile // Has no UnmarshalXML method, avoiding recursion.
+	v := (*tile)(t)
+	v.Probability = 1 // Default, omitted by Tiled.
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "class":
+			v.Type = a.Value
+		case "terrain":
+			t.terrain = parseTerrain(a.Value)
+		}
+	}
+	return d.DecodeElement(v, &start)
+}
+
+// Returns the tile with the given ID, with the defaults of TMX files.
+func NewTile(id ID) Tile {
+	return Tile{ID: id, Probability: 1}
+}
+
+// Number of columns of tiles in the tileset's image.
+func (ts *Tileset) columns() int {
+	if ts.Columns > 0 {
+		return ts.Columns
+	}
+	if ts.TileWidth+ts.Spacing <= 0 {
+		return 0
+	}
+	return (ts.Image.Width - 2*ts.Margin + ts.Spacing) / (ts.TileWidth + ts.Spacing)
+}
+
+// Returns the image the tile with the given ID is part of: the tileset's Image, or that of the tile in image
+// collection tilesets. Returns nil if there is none.
+func (ts *Tileset) TileImage(id ID) *Image {
+	if ts.Image.Source != "" {
+		return &ts.Image
+	}
+	if t := ts.Tile(id); t != nil && t.Image.Source != "" {
+		return &t.Image
+	}
+	return nil
+}
+
+// Returns the rectangle of the tile with the given ID within its image, see TileImage. The tiles of a single image
+// are laid out in rows of Columns tiles, starting Margin pixels from its top-left corner and Spacing pixels apart.
+// Tiles of image collections take the rectangle given by their X, Y, Width and Height, or all of their image.
+// Returns the empty rectangle if the tileset has no such tile.
+func (ts *Tileset) TileRect(id ID) image.Rectangle {
+	if ts.Image.Source == "" {
+		t := ts.Tile(id)
+		if t == nil {
+			return image.Rectangle{}
+		}
+		width, height := t.Width, t.Height
+		if width == 0 && height == 0 {
+			width, height = t.Image.Width, t.Image.Height
+		}
+		return image.Rect(t.X, t.Y, t.X+width, t.Y+height)
+	}
+
+	columns := ts.columns()
+	if columns <= 0 || int(id) >= ts.tileCount() {
+		return image.Rectangle{}
+	}
+	x := ts.Margin + int(id)%columns*(ts.TileWidth+ts.Spacing)
+	y := ts.Margin + int(id)/columns*(ts.TileHeight+ts.Spacing)
+	return image.Rect(x, y, x+ts.TileWidth, y+ts.TileHeight)
+}
+
+// Returns the image the tile is part of, or nil; see Tileset.TileImage.
+func (t *DecodedTile) Image() *Image {
+	if t.Nil || t.Tileset == nil {
+		return nil
+	}
+	return t.Tileset.TileImage(t.ID)
+}
+
+// Returns the rectangle of the tile within its image, before flipping; see Tileset.TileRect.
+func (t *DecodedTile) Rect() image.Rectangle {
+	if t.Nil || t.Tileset == nil {
+		return image.Rectangle{}
+	}
+	return t.Tileset.TileRect(t.ID)
+}
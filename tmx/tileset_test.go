@@ -0,0 +1,194 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+)
+
+// Reports the differences of a tileset and ts2 in the attributes added by Tiled 1.x.
+func compareTilesets(t *testing.T, name string, ts, ts2 *Tileset) {
+	if ts2.Class != ts.Class || ts2.ObjectAlignment != ts.ObjectAlignment || ts2.TileRenderSize != ts.TileRenderSize ||
+		ts2.FillMode != ts.FillMode || ts2.TileOffset != ts.TileOffset || ts2.Grid != ts.Grid || ts2.Transformations != ts.Transformations {
+		t.Errorf("%s: tileset attributes differ: %+v", name, *ts2)
+	}
+	if len(ts2.Tiles) != len(ts.Tiles) {
+		t.Error(name, "wrong number of tiles")
+		return
+	}
+	for i := range ts.Tiles {
+		tile, tile2 := &ts.Tiles[i], &ts2.Tiles[i]
+		if tile2.ID != tile.ID || tile2.Type != tile.Type || tile2.Probability != tile.Probability || tile2.X != tile.X ||
+			tile2.Y != tile.Y || tile2.Width != tile.Width || tile2.Height != tile.Height || len(tile2.Properties) != len(tile.Properties) {
+			t.Errorf("%s: tile %d differs: %+v", name, tile.ID, *tile2)
+		}
+	}
+}
+
+func TestTilesetAttributes(t *testing.T) {
+	for _, name := range []string{"testdata/tilesets/isometric.tsx", "testdata/tilesets/isometric.tsj"} {
+		ts := readTestTileset(t, name)
+
+		want := &Tileset{
+			Class:           "terrain",
+			ObjectAlignment: "bottom",
+			TileRenderSize:  "grid",
+			FillMode:        "preserve-aspect-fit",
+			TileOffset:      TileOffset{0, 4},
+			Grid:            Grid{"isometric", 32, 16},
+			Transformations: Transformations{HFlip: true, Rotate: true, PreferUntransformed: true},
+			Tiles:           []Tile{{ID: 4, Type: "water", Probability: 0.25, Properties: Properties{{Name: "depth"}}}},
+		}
+		compareTilesets(t, name, want, ts)
+
+		if tile := ts.Tile(4); tile == nil {
+			t.Error(name, "no tile 4")
+		} else if depth, err := tile.Properties.Int("depth"); depth != 3 || err != nil {
+			t.Error(name, "wrong tile property", depth, err)
+		}
+	}
+}
+
+func TestTilesetDefaults(t *testing.T) {
+	ts := readTestTileset(t, "testdata/tilesets/collection.tsx")
+	if ts.ObjectAlignment != "unspecified" || ts.TileRenderSize != "tile" || ts.FillMode != "stretch" {
+		t.Error("Wrong defaults", ts.ObjectAlignment, ts.TileRenderSize, ts.FillMode)
+	}
+	if ts.Tiles[0].Probability != 1 || ts.Tiles[1].Type != "sky" {
+		t.Error("Wrong tile defaults or class", ts.Tiles[0].Probability, ts.Tiles[1].Type)
+	}
+}
+
+func TestTileRect(t *testing.T) {
+	isometric := readTestTileset(t, "testdata/tilesets/isometric.tsx")
+	collection := readTestTileset(t, "testdata/tilesets/collection.tsx")
+
+	tests := []struct {
+		ts   *Tileset
+		id   ID
+		rect image.Rectangle
+	}{
+		{isometric, 0, image.Rect(1, 1, 17, 9)},
+		{isometric, 2, image.Rect(37, 1, 53, 9)},
+		{isometric, 4, image.Rect(19, 11, 35, 19)},
+		{isometric, 6, image.Rectangle{}},
+		{collection, 0, image.Rect(16, 8, 24, 16)},
+		{collection, 3, image.Rect(0, 0, 112, 16)},
+		{collection, 1, image.Rectangle{}},
+	}
+	for _, test := range tests {
+		if r := test.ts.TileRect(test.id); r != test.rect {
+			t.Error(test.ts.Name, test.id, "wrong rectangle", r)
+		}
+	}
+
+	isometric.FirstGID, collection.FirstGID = 1, 7
+	m := &Map{Tilesets: []Tileset{*isometric, *collection}}
+	for _, test := range []struct {
+		gid    GID
+		source string
+		rect   image.Rectangle
+	}{
+		{5 | GIDHorizontalFlip, "isometric.png", image.Rect(19, 11, 35, 19)},
+		{7, "../tiles.png", image.Rect(16, 8, 24, 16)},
+	} {
+		tile, err := m.DecodeGID(test.gid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img := tile.Image(); img == nil || img.Source != test.source || tile.Rect() != test.rect {
+			t.Error(test.gid, "wrong image or rectangle", img, tile.Rect())
+		}
+	}
+	if NilTile.Image() != nil || NilTile.Rect() != (image.Rectangle{}) {
+		t.Error("Nil tile has an image")
+	}
+}
+
+func TestWriteTilesetAttributes(t *testing.T) {
+	for _, name := range []string{"testdata/tilesets/isometric.tsx", "testdata/tilesets/collection.tsx"} {
+		ts := readTestTileset(t, name)
+
+		for _, write := range []func(*bytes.Buffer) error{
+			func(b *bytes.Buffer) error { return ts.Write(b) },
+			func(b *bytes.Buffer) error { return ts.WriteJSON(b) },
+		} {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			ts2, err := ReadTileset(&buf)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			compareTilesets(t, name, ts, ts2)
+		}
+	}
+}
+
+func TestWriteProbability(t *testing.T) {
+	read, err := ReadTileset(strings.NewReader(`<tileset name="t" tilewidth="8" tileheight="8"><tile id="3" probability="0"/></tileset>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Tiles and colors made with NewTile and NewWangColor have the default probability, zero tiles and those read
+	// as such are never picked.
+	half := NewTile(1)
+	half.Probability = 0.5
+	ts := &Tileset{
+		Name: "t", TileWidth: 8, TileHeight: 8,
+		Tiles:    []Tile{NewTile(0), half, {ID: 2}, read.Tiles[0]},
+		WangSets: []WangSet{NewWangSet("w", "corner")},
+	}
+	ts.WangSets[0].Colors = []WangColor{NewWangColor("c", "#ff0000")}
+
+	for _, write := range []func(*bytes.Buffer) error{
+		func(b *bytes.Buffer) error { return ts.Write(b) },
+		func(b *bytes.Buffer) error { return ts.WriteJSON(b) },
+	} {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(buf.String(), `probability="1"`) {
+			t.Error("Default probability written")
+		}
+		ts2, err := ReadTileset(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i, want := range []float64{1, 0.5, 0, 0} {
+			if p := ts2.Tiles[i].Probability; p != want {
+				t.Error("Wrong probability of tile", i, p)
+			}
+		}
+		if p := ts2.WangSets[0].Colors[0].Probability; p != 1 {
+			t.Error("Wrong probability of wang color", p)
+		}
+	}
+}
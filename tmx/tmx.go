@@ -24,17 +24,22 @@
 package tmx
 
 import (
+	"bufio"
 	"bytes"
-	"compress/gzip"
-	"compress/zlib"
+	"context"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/xml"
 	"errors"
+	"image"
 	"io"
-	"io/ioutil"
-	"os"
+	"io/fs"
+	"math"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -51,6 +56,9 @@ var (
 	InvalidDecodedDataLen = errors.New("tmx: invalid decoded data length")
 	InvalidGID            = errors.New("tmx: invalid GID")
 	InvalidPointsField    = errors.New("tmx: invalid points string")
+	InvalidColor          = errors.New("tmx: invalid color")
+	InvalidWangID         = errors.New("tmx: invalid wang ID")
+	UnknownOrientation    = errors.New("tmx: invalid orientation")
 )
 
 var (
@@ -62,31 +70,65 @@ type ID uint32
 
 // All structs have their fields exported, and you'll be on the safe side as long as treat them read-only (anyone want to write 100 getters?).
 type Map struct {
-	Version      string        `xml:"title,attr"`
-	Orientation  string        `xml:"orientation,attr"`
-	Width        int           `xml:"width,attr"`
-	Height       int           `xml:"height,attr"`
-	TileWidth    int           `xml:"tilewidth,attr"`
-	TileHeight   int           `xml:"tileheight,attr"`
-	Properties   []Property    `xml:"properties>property"`
-	Tilesets     []Tileset     `xml:"tileset"`
-	Layers       []Layer       `xml:"layer"`
-	ObjectGroups []ObjectGroup `xml:"objectgroup"`
+	Version         string        `xml:"version,attr"`      // Version of the TMX format.
+	TiledVersion    string        `xml:"tiledversion,attr"` // Version of Tiled the map was saved with.
+	Class           string        `xml:"class,attr"`
+	Orientation     string        `xml:"orientation,attr"` // One of orthogonal, isometric, staggered or hexagonal.
+	RenderOrder     string        `xml:"renderorder,attr"` // One of right-down (the default), right-up, left-down or left-up.
+	Width           int           `xml:"width,attr"`
+	Height          int           `xml:"height,attr"`
+	TileWidth       int           `xml:"tilewidth,attr"`
+	TileHeight      int           `xml:"tileheight,attr"`
+	HexSideLength   int           `xml:"hexsidelength,attr"` // Only used by hexagonal maps.
+	StaggerAxis     string        `xml:"staggeraxis,attr"`   // x or y; only used by staggered and hexagonal maps.
+	StaggerIndex    string        `xml:"staggerindex,attr"`  // odd or even; only used by staggered and hexagonal maps.
+	ParallaxOriginX float64       `xml:"parallaxoriginx,attr"`
+	ParallaxOriginY float64       `xml:"parallaxoriginy,attr"`
+	BackgroundColor string        `xml:"backgroundcolor,attr"` // As #AARRGGBB or #RRGGBB.
+	NextLayerID     ID            `xml:"nextlayerid,attr"`     // The ID the next layer added to the map gets.
+	NextObjectID    ID            `xml:"nextobjectid,attr"`    // The ID the next object added to the map gets.
+	Infinite        bool          `xml:"infinite,attr"`        // Layer data of infinite maps is stored in chunks, see Layer.TileAt.
+	Properties      Properties    `xml:"properties>property"`
+	Tilesets        []Tileset     `xml:"tileset"`
+	LayerTree       []LayerNode   `xml:",any"` // All layers in document (drawing) order, with groups holding their children.
+	Layers          []Layer       `xml:"-"`    // All tile layers of LayerTree in document order, including those inside groups.
+	ObjectGroups    []ObjectGroup `xml:"-"`    // All object groups of LayerTree in document order, including those inside groups.
+	ImageLayers     []ImageLayer  `xml:"-"`    // All image layers of LayerTree in document order, including those inside groups.
+
+	fsys fs.FS     // The file system the map was loaded from; see Map.Open.
+	gids *gidTable // Resolves GIDs to Tilesets; see Map.tilesetOf.
+}
+
+func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type tmxMap Map // Has no UnmarshalXML method, avoiding recursion.
+	v := (*tmxMap)(m)
+	v.RenderOrder = "right-down" // Default, omitted by Tiled.
+	return d.DecodeElement(v, &start)
 }
 
 type Tileset struct {
-	FirstGID   GID        `xml:"firstgid,attr"`
-	Source     string     `xml:"source,attr"`
-	Name       string     `xml:"name,attr"`
-	TileWidth  int        `xml:"tilewidth,attr"`
-	TileHeight int        `xml:"tileheight,attr"`
-	Spacing    int        `xml:"spacing,attr"`
-	Margin     int        `xml:"margin,attr"`
-	Properties []Property `xml:"properties>property"`
-	Image      Image      `xml:"image"`
-	Tiles      []Tile     `xml:"tile"`
-	Tilecount  int        `xml:"tilecount,attr"`
-	Columns    int        `xml:"columns,attr"`
+	FirstGID        GID             `xml:"firstgid,attr"`
+	Source          string          `xml:"source,attr"`
+	Name            string          `xml:"name,attr"`
+	Class           string          `xml:"class,attr"`
+	TileWidth       int             `xml:"tilewidth,attr"`
+	TileHeight      int             `xml:"tileheight,attr"`
+	Spacing         int             `xml:"spacing,attr"`
+	Margin          int             `xml:"margin,attr"`
+	ObjectAlignment string          `xml:"objectalignment,attr"` // Where tile objects are anchored: unspecified (the default), topleft, top, ..., bottomright.
+	TileRenderSize  string          `xml:"tilerendersize,attr"`  // tile (the default) or grid: the size tiles are drawn at.
+	FillMode        string          `xml:"fillmode,attr"`        // stretch (the default) or preserve-aspect-fit, when tiles are drawn at the grid size.
+	TileOffset      TileOffset      `xml:"tileoffset"`
+	Grid            Grid            `xml:"grid"`
+	Transformations Transformations `xml:"transformations"`
+	Properties      Properties      `xml:"properties>property"`
+	Image           Image           `xml:"image"` // Empty for image collection tilesets, whose tiles each have their own.
+	Tiles           []Tile          `xml:"tile"`
+	WangSets        []WangSet       `xml:"wangsets>wangset"` // Terrains of Tiled before 1.5 are read as a corner wang set.
+	Tilecount       int             `xml:"tilecount,attr"`
+	Columns         int             `xml:"columns,attr"`
+
+	file string // Path of the file the tileset was defined in, in its Loader's file system.
 }
 
 type Image struct {
@@ -94,52 +136,117 @@ type Image struct {
 	Trans  string `xml:"trans,attr"`
 	Width  int    `xml:"width,attr"`
 	Height int    `xml:"height,attr"`
+	Path   string `xml:"-"` // Source resolved against the file referencing the image; to be used with Map.Open.
 }
 
+// Data of a tile of a tileset. The zero Tile is never picked by the terrain tools; tiles made in Go start from
+// NewTile.
 type Tile struct {
-	ID    ID    `xml:"id,attr"`
-	Image Image `xml:"image"`
+	ID          ID           `xml:"id,attr"`
+	Type        string       `xml:"type,attr"`        // The class of the tile; stored as class by Tiled 1.9.
+	Probability float64      `xml:"probability,attr"` // Relative chance of the tile being picked by the terrain tools, 1 by default.
+	X           int          `xml:"x,attr"`           // The part of Image the tile uses, in image collection tilesets;
+	Y           int          `xml:"y,attr"`           // Width and Height are zero when it is all of it. See Tileset.TileRect.
+	Width       int          `xml:"width,attr"`
+	Height      int          `xml:"height,attr"`
+	Properties  Properties   `xml:"properties>property"`
+	Image       Image        `xml:"image"`
+	Animation   []Frame      `xml:"animation>frame"`
+	ObjectGroup *ObjectGroup `xml:"objectgroup"` // Collision shapes of the tile, relative to its top-left corner.
+
+	terrain []int // Terrain types of Tiled before 1.5, until converted into a wang set; see parseTerrain.
 }
 
 type Layer struct {
-	Name         string         `xml:"name,attr"`
-	Opacity      float32        `xml:"opacity,attr"`
-	Visible      bool           `xml:"visible,attr"`
-	Properties   []Property     `xml:"properties>property"`
-	Data         Data           `xml:"data"`
-	DecodedTiles []*DecodedTile // This is the attiribute you'd like to use, not Data. Tile entry at (x,y) is obtained using l.DecodedTiles[y*map.Width+x].
-	Tileset      *Tileset       // This is only set when the layer uses a single tileset and NilLayer is false.
-	Empty        bool           // Set when all entries of the layer are NilTile
+	LayerBase
+	Width   int      `xml:"width,attr"`
+	Height  int      `xml:"height,attr"`
+	Data    Data     `xml:"data"`
+	GIDs    []GID    `xml:"-"` // Decoded Data, flip bits included. The GID at (x,y) is l.GIDs[y*l.Width+x]; see also DecodedTiles and TileAt. Empty for infinite maps.
+	Tileset *Tileset // This is only set when the layer uses a single tileset and NilLayer is false.
+	Empty   bool     // Set when all entries of the layer are NilTile
+
+	m      *Map       // The map the layer belongs to, against whose tilesets GIDs are resolved.
+	chunks chunkIndex // Where GIDAt and SetGIDAt find the chunks of infinite maps.
 }
 
 type Data struct {
 	Encoding    string     `xml:"encoding,attr"`
 	Compression string     `xml:"compression,attr"`
-	RawData     []byte     `xml:",innerxml"`
-	DataTiles   []DataTile `xml:"tile"` // Only used when layer encoding is xml
+	RawData     []byte     `xml:",chardata"` // Text of the element. White space is left out in the csv and base64 encodings.
+	DataTiles   []DataTile `xml:"tile"`      // Only used when layer encoding is xml
+	Chunks      []Chunk    `xml:"chunk"`     // Only used in infinite maps
+}
+
+// A rectangular piece of layer data in an infinite map. It is encoded as specified by the Data it belongs to.
+type Chunk struct {
+	X         int        `xml:"x,attr"`
+	Y         int        `xml:"y,attr"`
+	Width     int        `xml:"width,attr"`
+	Height    int        `xml:"height,attr"`
+	RawData   []byte     `xml:",chardata"`
+	DataTiles []DataTile `xml:"tile"`
+	GIDs      []GID      `xml:"-"` // The GID at (x,y), relative to the chunk, is c.GIDs[y*c.Width+x].
+
+	m *Map
 }
 
 type ObjectGroup struct {
-	Name       string     `xml:"name,attr"`
-	Color      string     `xml:"color,attr"`
-	Opacity    float32    `xml:"opacity,attr"`
-	Visible    bool       `xml:"visible,attr"`
-	Properties []Property `xml:"properties>property"`
-	Objects    []Object   `xml:"object"`
+	LayerBase
+	Color   string   `xml:"color,attr"`
+	Objects []Object `xml:"object"`
+}
+
+func (l *Layer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type layer Layer // Has no UnmarshalXML method, avoiding recursion.
+	v := (*layer)(l)
+	v.LayerBase.defaults()
+	return d.DecodeElement(v, &start)
+}
+
+func (g *ObjectGroup) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type objectGroup ObjectGroup // Has no UnmarshalXML method, avoiding recursion.
+	v := (*objectGroup)(g)
+	v.LayerBase.defaults()
+	return d.DecodeElement(v, &start)
 }
 
+// An object of an object group. The zero Object is invisible; objects made in Go start from NewObject.
 type Object struct {
+	ID         ID         `xml:"id,attr"` // Unique within the map; objects refer to each other by ID.
 	Name       string     `xml:"name,attr"`
-	Type       string     `xml:"type,attr"`
+	Type       string     `xml:"type,attr"` // The class of the object; stored as class by Tiled 1.9.
 	X          float64    `xml:"x,attr"`
 	Y          float64    `xml:"y,attr"`
 	Width      float64    `xml:"width,attr"`
 	Height     float64    `xml:"height,attr"`
+	Rotation   float64    `xml:"rotation,attr"` // In degrees, clockwise around (X, Y).
 	GID        int        `xml:"gid,attr"`
 	Visible    bool       `xml:"visible,attr"`
+	Template   string     `xml:"template,attr"` // An object template (TX file) this object is based on. Already applied by the loader.
+	Ellipse    *struct{}  `xml:"ellipse"`       // Set when the object is an ellipse.
+	Point      *struct{}  `xml:"point"`         // Set when the object is a point.
+	Text       *Text      `xml:"text"`          // Set when the object is a text.
 	Polygons   []Polygon  `xml:"polygon"`
 	PolyLines  []PolyLine `xml:"polyline"`
-	Properties []Property `xml:"properties>property"`
+	Properties Properties `xml:"properties>property"`
+}
+
+// Returns an object with the given ID and the defaults of TMX files.
+func NewObject(id ID) Object {
+	return Object{ID: id, Visible: true}
+}
+
+func (o *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type object Object // Has no UnmarshalXML method, avoiding recursion.
+	v := (*object)(o)
+	v.Visible = true // Default, omitted by Tiled.
+	for _, a := range start.Attr {
+		if a.Name.Local == "class" {
+			v.Type = a.Value
+		}
+	}
+	return d.DecodeElement(v, &start)
 }
 
 type Polygon struct {
@@ -151,149 +258,481 @@ type PolyLine struct {
 }
 
 type Property struct {
-	Name  string `xml:"name,attr"`
-	Value string `xml:"value,attr"`
+	Name         string     `xml:"name,attr"`
+	Type         string     `xml:"type,attr"`           // One of string (or empty), int, float, bool, color, file, object or class.
+	PropertyType string     `xml:"propertytype,attr"`   // Name of the custom type of class properties.
+	Value        string     `xml:"value,attr"`          // Multi-line strings, stored as the text of the element, end up here too.
+	Properties   Properties `xml:"properties>property"` // Members of class properties.
+
+	file string // The file the property was read from, against which file properties are resolved.
+	m    *Map   // The map the property belongs to, in which object properties are looked up.
 }
 
-func (d *Data) decodeBase64() (data []byte, err error) {
-	rawData := bytes.TrimSpace(d.RawData)
-	r := bytes.NewReader(rawData)
+// Decodes base64 data, and decompresses it, into a buffer from the pool. The buffer holds at most 4n+1 bytes,
+// so that data of more than n tiles is noticed without decompressing all of it, and grows with the data rather
+// than being sized from n, which comes from the dimensions a map claims.
+func (d *Data) decodeBase64(n int) (data []byte, buf *[]byte, err error) {
+	enc := getBuffer(base64.StdEncoding.DecodedLen(len(d.RawData)))
+	m, err := base64.StdEncoding.Decode(*enc, d.RawData)
+	if err != nil || d.Compression == "" {
+		return (*enc)[:m], enc, err
+	}
+	defer putBuffer(enc)
 
-	encr := base64.NewDecoder(base64.StdEncoding, r)
+	c, ok := lookupCompression(d.Compression)
+	if !ok || c.decompress == nil {
+		return nil, nil, UnknownCompression
+	}
 
-	var comr io.Reader
-	switch d.Compression {
-	case "gzip":
-		comr, err = gzip.NewReader(encr)
-		if err != nil {
-			return
-		}
-	case "zlib":
-		comr, err = zlib.NewReader(encr)
-		if err != nil {
-			return
-		}
-	case "":
-		comr = encr
-	default:
-		err = UnknownCompression
-		return
+	comr, err := c.decompress(bytes.NewReader((*enc)[:m]))
+	if err != nil {
+		return nil, nil, err
+	}
+	if closer, ok := comr.(io.Closer); ok {
+		defer closer.Close()
 	}
 
-	return ioutil.ReadAll(comr)
+	buf = getBuffer(0)
+	*buf, err = readAppend(*buf, io.LimitReader(comr, 4*int64(n)+1))
+	return *buf, buf, err
 }
 
-func (d *Data) decodeCSV() (data []GID, err error) {
-	cleaner := func(r rune) rune {
-		if (r >= '0' && r <= '9') || r == ',' {
-			return r
+// Like io.ReadAll, but appends to b.
+func readAppend(b []byte, r io.Reader) ([]byte, error) {
+	for {
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
+		}
+		m, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+m]
+		if err == io.EOF {
+			return b, nil
 		}
-		return -1
-	}
-	rawDataClean := strings.Map(cleaner, string(d.RawData))
-
-	str := strings.Split(string(rawDataClean), ",")
-
-	gids := make([]GID, len(str))
-	for i, s := range str {
-		var d uint64
-		d, err = strconv.ParseUint(s, 10, 32)
 		if err != nil {
-			return
+			return b, err
 		}
-		gids[i] = GID(d)
 	}
-	return gids, err
 }
 
-func (m *Map) decodeLayerXML(l *Layer) (gids []GID, err error) {
-	if len(l.Data.DataTiles) != m.Width*m.Height {
+func (d *Data) decodeXML(n int) (gids []GID, err error) {
+	if len(d.DataTiles) != n {
 		return []GID{}, InvalidDecodedDataLen
 	}
 
-	gids = make([]GID, len(l.Data.DataTiles))
+	gids = make([]GID, len(d.DataTiles))
 	for i := 0; i < len(gids); i++ {
-		gids[i] = l.Data.DataTiles[i].GID
+		gids[i] = d.DataTiles[i].GID
 	}
 
 	return gids, nil
 }
 
-func (m *Map) decodeLayerCSV(l *Layer) ([]GID, error) {
-	gids, err := l.Data.decodeCSV()
-	if err != nil {
-		return []GID{}, err
+// Parses comma separated GIDs, ignoring white space, without making a copy of the data.
+func (d *Data) decodeCSVn(n int) ([]GID, error) {
+	raw := d.RawData
+	// n comes from the dimensions the map claims, and may be far more than the data holds.
+	size := bytes.Count(raw, []byte{','}) + 1
+	if n < size {
+		size = n
+	}
+	gids := make([]GID, 0, size)
+
+	var v uint64
+	start, digits := 0, 0
+	for i := 0; i <= len(raw); i++ {
+		if i == len(raw) || raw[i] == ',' {
+			if digits == 0 {
+				if i == len(raw) && len(gids) == 0 && n == 0 {
+					break
+				}
+				return []GID{}, dataError(&strconv.NumError{Func: "ParseUint", Num: string(raw[start:i]), Err: strconv.ErrSyntax}, start, len(gids))
+			}
+			if len(gids) == n {
+				return []GID{}, dataError(InvalidDecodedDataLen, start, -1)
+			}
+			gids = append(gids, GID(v))
+			v, start, digits = 0, i+1, 0
+			continue
+		}
+
+		switch c := raw[i]; {
+		case c >= '0' && c <= '9':
+			v = v*10 + uint64(c-'0')
+			if v > math.MaxUint32 {
+				return []GID{}, dataError(&strconv.NumError{Func: "ParseUint", Num: string(raw[start : i+1]), Err: strconv.ErrRange}, start, len(gids))
+			}
+			digits++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		default:
+			return []GID{}, dataError(&strconv.NumError{Func: "ParseUint", Num: string(raw[start : i+1]), Err: strconv.ErrSyntax}, i, len(gids))
+		}
 	}
 
-	if len(gids) != m.Width*m.Height {
+	if len(gids) != n {
 		return []GID{}, InvalidDecodedDataLen
 	}
 
 	return gids, nil
 }
 
-func (m *Map) decodeLayerBase64(l *Layer) ([]GID, error) {
-	dataBytes, err := l.Data.decodeBase64()
+func (d *Data) decodeBase64n(n int) ([]GID, error) {
+	dataBytes, buf, err := d.decodeBase64(n)
+	if buf != nil {
+		defer putBuffer(buf)
+	}
+	if offset, ok := err.(base64.CorruptInputError); ok {
+		return []GID{}, dataError(err, int(offset), -1)
+	}
 	if err != nil {
 		return []GID{}, err
 	}
 
-	if len(dataBytes) != m.Width*m.Height*4 {
+	if len(dataBytes) != n*4 {
 		return []GID{}, InvalidDecodedDataLen
 	}
 
-	gids := make([]GID, m.Width*m.Height)
-
-	j := 0
-	for y := 0; y < m.Height; y++ {
-		for x := 0; x < m.Width; x++ {
-			gid := GID(dataBytes[j]) +
-				GID(dataBytes[j+1])<<8 +
-				GID(dataBytes[j+2])<<16 +
-				GID(dataBytes[j+3])<<24
-			j += 4
-
-			gids[y*m.Width+x] = gid
-		}
+	gids := make([]GID, n)
+	for i := 0; i < n; i++ {
+		gids[i] = GID(binary.LittleEndian.Uint32(dataBytes[4*i:]))
 	}
 
 	return gids, nil
 }
 
-func (m *Map) decodeLayer(l *Layer) ([]GID, error) {
-	switch l.Data.Encoding {
+// Decodes the data, which must hold exactly n tiles.
+func (d *Data) decode(n int) ([]GID, error) {
+	switch d.Encoding {
 	case "csv":
-		return m.decodeLayerCSV(l)
+		return d.decodeCSVn(n)
 	case "base64":
-		return m.decodeLayerBase64(l)
+		return d.decodeBase64n(n)
 	case "": // XML "encoding"
-		return m.decodeLayerXML(l)
+		return d.decodeXML(n)
+	}
+	return d.decodeCustom(n)
+}
+
+// Decodes data in an encoding registered with RegisterEncoding.
+func (d *Data) decodeCustom(n int) ([]GID, error) {
+	enc, ok := lookupEncoding(d.Encoding)
+	if !ok || enc.decode == nil {
+		return []GID{}, UnknownEncoding
+	}
+
+	gids, err := enc.decode(bytes.TrimSpace(d.RawData))
+	if err != nil {
+		return []GID{}, err
+	}
+
+	if len(gids) != n {
+		return []GID{}, InvalidDecodedDataLen
+	}
+
+	return gids, nil
+}
+
+// Data of a chunk is encoded the same way as the layer data it is part of.
+func (c *Chunk) data(d *Data) *Data {
+	return &Data{
+		Encoding:    d.Encoding,
+		Compression: d.Compression,
+		RawData:     c.RawData,
+		DataTiles:   c.DataTiles,
+	}
+}
+
+func (m *Map) decodeLayer(l *Layer) ([]GID, error) {
+	return l.Data.decode(l.Width * l.Height)
+}
+
+// Checks that all GIDs belong to a tileset of the map.
+func (m *Map) checkGIDs(gids []GID) error {
+	for i, gid := range gids {
+		if gid != 0 && m.tilesetOf(gid&^GIDFlip) == nil {
+			return dataError(InvalidGID, -1, i)
+		}
 	}
-	return []GID{}, UnknownEncoding
+	return nil
 }
 
-func (m *Map) decodeLayers() (err error) {
+// Decodes all layers, or all chunks of infinite maps, using up to workers goroutines. Each one is decoded into
+// its own slice, so that they can be decoded in any order; the error returned is that of the first one failing.
+func (m *Map) decodeLayers(ctx context.Context, workers int) error {
+	m.gids = newGIDTable(m.Tilesets)
+
+	var jobs []func() error
 	for i := 0; i < len(m.Layers); i++ {
-		l := &m.Layers[i]
-		var gids []GID
-		if gids, err = m.decodeLayer(l); err != nil {
+		i, l := i, &m.Layers[i]
+		l.m = m
+		if l.Width == 0 && l.Height == 0 {
+			l.Width, l.Height = m.Width, m.Height
+		}
+
+		if !m.Infinite {
+			jobs = append(jobs, func() (err error) {
+				if l.GIDs, err = m.decodeLayer(l); err == nil {
+					err = m.checkGIDs(l.GIDs)
+				}
+				if err != nil {
+					return l.locate(err, i, nil)
+				}
+				return nil
+			})
+			continue
+		}
+
+		l.indexChunks()
+		for j := 0; j < len(l.Data.Chunks); j++ {
+			c := &l.Data.Chunks[j]
+			c.m = m
+			jobs = append(jobs, func() (err error) {
+				if c.GIDs, err = c.data(&l.Data).decode(c.Width * c.Height); err == nil {
+					err = m.checkGIDs(c.GIDs)
+				}
+				if err != nil {
+					return l.locate(err, i, c)
+				}
+				return nil
+			})
+		}
+	}
+
+	return runJobs(ctx, jobs, workers)
+}
+
+// Runs the jobs on up to workers goroutines (GOMAXPROCS if negative), and returns the error of the first job,
+// by index, that fails. Jobs are started in order, and none is started once one before it has failed.
+// A job started after ctx is done fails with its error.
+func runJobs(ctx context.Context, jobs []func() error, workers int) error {
+	if workers < 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	run := func(job func() error) error {
+		if err := ctx.Err(); err != nil {
 			return err
 		}
+		return job()
+	}
 
-		l.DecodedTiles = make([]*DecodedTile, len(gids))
-		for j := 0; j < len(l.DecodedTiles); j++ {
-			l.DecodedTiles[j], err = m.DecodeGID(gids[j])
-			if err != nil {
+	if workers <= 1 {
+		for _, job := range jobs {
+			if err := run(job); err != nil {
 				return err
 			}
 		}
+		return nil
+	}
+
+	var (
+		next   int64 = -1
+		failed int64 = int64(len(jobs)) // Index of the first job known to have failed.
+		errs         = make([]error, len(jobs))
+		wg     sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := atomic.AddInt64(&next, 1)
+				if i >= atomic.LoadInt64(&failed) {
+					return
+				}
+				if errs[i] = run(jobs[i]); errs[i] == nil {
+					continue
+				}
+				for f := atomic.LoadInt64(&failed); i < f && !atomic.CompareAndSwapInt64(&failed, f, i); {
+					f = atomic.LoadInt64(&failed)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the GID at cell (x, y), or 0 if the layer has none there.
+// Coordinates can be negative in infinite maps, whose chunks are looked up by their position.
+func (l *Layer) GIDAt(x, y int) GID {
+	if len(l.Data.Chunks) == 0 {
+		if x < 0 || y < 0 || x >= l.Width || y >= l.Height || len(l.GIDs) != l.Width*l.Height {
+			return 0
+		}
+		return l.GIDs[y*l.Width+x]
+	}
+
+	if c := l.chunkAt(x, y); c != nil && len(c.GIDs) == c.Width*c.Height {
+		return c.GIDs[(y-c.Y)*c.Width+x-c.X]
+	}
+	return 0
+}
+
+// Sets the GID at cell (x, y), and reports whether the layer has data there to hold it. Layers without data
+// are given Width x Height cells first, unless they have chunks. Neither is gid checked against the tilesets of
+// the map, nor are Tileset and Empty updated.
+func (l *Layer) SetGIDAt(x, y int, gid GID) bool {
+	if len(l.Data.Chunks) == 0 {
+		if x < 0 || y < 0 || x >= l.Width || y >= l.Height {
+			return false
+		}
+		if len(l.GIDs) == 0 {
+			l.GIDs = make([]GID, l.Width*l.Height)
+		}
+		if len(l.GIDs) != l.Width*l.Height {
+			return false
+		}
+		l.GIDs[y*l.Width+x] = gid
+		return true
+	}
+
+	if c := l.chunkAt(x, y); c != nil && len(c.GIDs) == c.Width*c.Height {
+		c.GIDs[(y-c.Y)*c.Width+x-c.X] = gid
+		return true
+	}
+	return false
+}
+
+// The chunks of a layer by their position on the grid of chunks, as Tiled lays them out: all of one size, at
+// multiples of it. Layers whose chunks are laid out otherwise have no index, and are scanned.
+type chunkIndex struct {
+	first *Chunk // The chunks indexed, to tell when the slice was replaced or grew.
+	n     int
+	size  image.Point
+	at    map[image.Point]int
+}
+
+// Indexes the chunks of the layer. The first chunk holding a cell is the one found.
+func (l *Layer) indexChunks() {
+	chunks := l.Data.Chunks
+	l.chunks = chunkIndex{n: len(chunks)}
+	if len(chunks) == 0 || chunks[0].Width <= 0 || chunks[0].Height <= 0 {
+		return
+	}
+
+	size := image.Pt(chunks[0].Width, chunks[0].Height)
+	at := make(map[image.Point]int, len(chunks))
+	for i := range chunks {
+		c := &chunks[i]
+		p := image.Pt(floorDiv(c.X, size.X), floorDiv(c.Y, size.Y))
+		if c.Width != size.X || c.Height != size.Y || c.X != p.X*size.X || c.Y != p.Y*size.Y {
+			at = nil
+			break
+		}
+		if _, ok := at[p]; !ok {
+			at[p] = i
+		}
+	}
+	l.chunks = chunkIndex{&chunks[0], len(chunks), size, at}
+}
+
+// Returns the chunk holding cell (x, y), or nil. The index is built when the map is loaded, and again when the
+// chunks were replaced or appended to since; chunks moved in place are not looked up where they moved.
+func (l *Layer) chunkAt(x, y int) *Chunk {
+	chunks := l.Data.Chunks
+	if ix := &l.chunks; ix.n != len(chunks) || len(chunks) > 0 && ix.first != &chunks[0] {
+		l.indexChunks()
+	}
+
+	if ix := &l.chunks; ix.at != nil {
+		i, ok := ix.at[image.Pt(floorDiv(x, ix.size.X), floorDiv(y, ix.size.Y))]
+		if !ok {
+			return nil
+		}
+		if c := &chunks[i]; x >= c.X && y >= c.Y && x < c.X+c.Width && y < c.Y+c.Height {
+			return c
+		}
+		return nil
+	}
+
+	for i := range chunks {
+		if c := &chunks[i]; x >= c.X && y >= c.Y && x < c.X+c.Width && y < c.Y+c.Height {
+			return c
+		}
 	}
 	return nil
 }
 
+// Divides a by b > 0, rounding down.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b < 0 {
+		q--
+	}
+	return q
+}
+
+// Returns the tile at cell (x, y), or NilTile if the layer has none there.
+// Coordinates can be negative in infinite maps.
+func (l *Layer) TileAt(x, y int) *DecodedTile {
+	return l.m.decodeTile(l.GIDAt(x, y))
+}
+
+// Returns the tiles of all cells of the layer, resolved from GIDs: the tile at (x,y) is at index y*l.Width+x.
+// Empty for infinite maps. The slice is built on each call, with cells holding the same GID sharing one tile;
+// GIDs, GIDAt and TileAt give access to single cells without that cost.
+func (l *Layer) DecodedTiles() []*DecodedTile {
+	return l.m.decodeTiles(l.GIDs)
+}
+
+// Returns the tiles of all cells of the chunk, like Layer.DecodedTiles: the tile at (x,y), relative to the chunk,
+// is at index y*c.Width+x.
+func (c *Chunk) DecodedTiles() []*DecodedTile {
+	return c.m.decodeTiles(c.GIDs)
+}
+
+func (m *Map) decodeTiles(gids []GID) []*DecodedTile {
+	tiles := make([]*DecodedTile, len(gids))
+	seen := make(map[GID]*DecodedTile)
+	for i, gid := range gids {
+		t, ok := seen[gid]
+		if !ok {
+			t = m.decodeTile(gid)
+			seen[gid] = t
+		}
+		tiles[i] = t
+	}
+	return tiles
+}
+
+// Like DecodeGID, for GIDs that were checked when the map was loaded.
+func (m *Map) decodeTile(gid GID) *DecodedTile {
+	if m == nil {
+		return NilTile
+	}
+	t, err := m.DecodeGID(gid)
+	if err != nil {
+		return NilTile
+	}
+	return t
+}
+
+// Returns the rectangle of cells the layer stores data for. For infinite maps, this is the union of all chunks.
+func (l *Layer) Bounds() image.Rectangle {
+	if len(l.Data.Chunks) == 0 {
+		return image.Rect(0, 0, l.Width, l.Height)
+	}
+
+	var r image.Rectangle
+	for i := 0; i < len(l.Data.Chunks); i++ {
+		c := &l.Data.Chunks[i]
+		r = r.Union(image.Rect(c.X, c.Y, c.X+c.Width, c.Y+c.Height))
+	}
+	return r
+}
+
 type Point struct {
-	X int
-	Y int
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 type DataTile struct {
@@ -308,35 +747,49 @@ func (p *PolyLine) Decode() ([]Point, error) {
 }
 
 func decodePoints(s string) (points []Point, err error) {
-	pointStrings := strings.Split(s, " ")
+	pointStrings := strings.Fields(s)
+	if len(pointStrings) == 0 {
+		return []Point{}, InvalidPointsField
+	}
 
 	points = make([]Point, len(pointStrings))
+	offset := 0
 	for i, pointString := range pointStrings {
+		offset += strings.Index(s[offset:], pointString)
 		coordStrings := strings.Split(pointString, ",")
 		if len(coordStrings) != 2 {
-			return []Point{}, InvalidPointsField
+			return []Point{}, dataError(InvalidPointsField, offset, -1)
 		}
 
-		points[i].X, err = strconv.Atoi(coordStrings[0])
+		points[i].X, err = strconv.ParseFloat(coordStrings[0], 64)
 		if err != nil {
-			return []Point{}, err
+			return []Point{}, dataError(InvalidPointsField, offset, -1)
 		}
 
-		points[i].Y, err = strconv.Atoi(coordStrings[1])
+		points[i].Y, err = strconv.ParseFloat(coordStrings[1], 64)
 		if err != nil {
-			return []Point{}, err
+			return []Point{}, dataError(InvalidPointsField, offset+len(coordStrings[0])+1, -1)
 		}
+		offset += len(pointString)
 	}
 	return
 }
 
 func getTileset(m *Map, l *Layer) (tileset *Tileset, isEmpty, usesMultipleTilesets bool) {
-	for i := 0; i < len(l.DecodedTiles); i++ {
-		tile := l.DecodedTiles[i]
-		if !tile.Nil {
+	gids := l.GIDs
+	for i := 0; i <= len(l.Data.Chunks); i++ {
+		if i > 0 {
+			gids = l.Data.Chunks[i-1].GIDs
+		}
+
+		for _, gid := range gids {
+			if gid == 0 {
+				continue
+			}
+			ts := m.tilesetOf(gid &^ GIDFlip)
 			if tileset == nil {
-				tileset = tile.Tileset
-			} else if tileset != tile.Tileset {
+				tileset = ts
+			} else if tileset != ts {
 				return tileset, false, true
 			}
 		}
@@ -349,49 +802,41 @@ func getTileset(m *Map, l *Layer) (tileset *Tileset, isEmpty, usesMultipleTilese
 	return tileset, false, false
 }
 
+// Reads a map, in TMX or JSON (TMJ) format. Files referenced by the map are looked up relative to the current working directory;
+// use ReadFile or a Loader to have them resolved relative to the map file.
 func Read(r io.Reader) (*Map, error) {
-	d := xml.NewDecoder(r)
-
-	m := new(Map)
-	if err := d.Decode(m); err != nil {
-		return nil, err
-	}
-
-	err := m.decodeLayers()
-	if err != nil {
-		return nil, err
-	}
-
-	for i := 0; i < len(m.Layers); i++ {
-		l := &m.Layers[i]
-
-		tileset, isEmpty, usesMultipleTilesets := getTileset(m, l)
-		if usesMultipleTilesets {
-			continue
-		}
-		l.Empty, l.Tileset = isEmpty, tileset
-	}
+	return new(Loader).read(r, "")
+}
 
-	return m, nil
+// Like Read, but gives up with the error of ctx once it is done. Use a Loader to also limit the resources the map may use.
+func ReadContext(ctx context.Context, r io.Reader) (*Map, error) {
+	return new(Loader).ReadContext(ctx, r, "")
 }
 
+// Reads a map from the host file system. Files referenced by the map are resolved relative to it.
 func ReadFile(filePath string) (*Map, error) {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+	return new(Loader).ReadFile(filePath)
+}
+
+// Reads an external tileset (a TSX or TSJ file), as referenced by Tileset.Source.
+// FirstGID is left zero, since it is only known to the map using the tileset.
+func ReadTileset(r io.Reader) (*Tileset, error) {
+	br := bufio.NewReader(r)
+	if isJSON(br) {
+		return readJSONTileset(br)
 	}
 
-	defer f.Close()
+	d := xml.NewDecoder(br)
 
-	newMap, err := Read(f)
-	if err != nil {
+	ts := new(Tileset)
+	if err := d.Decode(ts); err != nil {
 		return nil, err
 	}
 
-	return newMap, err
-
+	return ts, nil
 }
 
+// Resolves a GID, flip bits included, to the tile it stands for. This takes constant time in maps read by the package.
 func (m *Map) DecodeGID(gid GID) (*DecodedTile, error) {
 	if gid == 0 {
 		return NilTile, nil
@@ -399,20 +844,19 @@ func (m *Map) DecodeGID(gid GID) (*DecodedTile, error) {
 
 	gidBare := gid &^ GIDFlip
 
-	for i := len(m.Tilesets) - 1; i >= 0; i-- {
-		if m.Tilesets[i].FirstGID <= gidBare {
-			return &DecodedTile{
-				ID:             ID(gidBare - m.Tilesets[i].FirstGID),
-				Tileset:        &m.Tilesets[i],
-				HorizontalFlip: gid&GIDHorizontalFlip != 0,
-				VerticalFlip:   gid&GIDVerticalFlip != 0,
-				DiagonalFlip:   gid&GIDDiagonalFlip != 0,
-				Nil:            false,
-			}, nil
-		}
+	ts := m.tilesetOf(gidBare)
+	if ts == nil {
+		return nil, InvalidGID // Should never hapen for a valid TMX file.
 	}
 
-	return nil, InvalidGID // Should never hapen for a valid TMX file.
+	return &DecodedTile{
+		ID:             ID(gidBare - ts.FirstGID),
+		Tileset:        ts,
+		HorizontalFlip: gid&GIDHorizontalFlip != 0,
+		VerticalFlip:   gid&GIDVerticalFlip != 0,
+		DiagonalFlip:   gid&GIDDiagonalFlip != 0,
+		Nil:            false,
+	}, nil
 }
 
 type DecodedTile struct {
@@ -23,12 +23,19 @@
 package tmx
 
 import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
 	"os"
+	"strings"
 	"testing"
 )
 
 var (
-	testfiles = []string{"testdata/base64.tmx", "testdata/base64-zlib.tmx", "testdata/csv.tmx", "testdata/xml.tmx"}
+	testfiles = []string{"testdata/base64.tmx", "testdata/base64-zlib.tmx", "testdata/base64-zstd.tmx", "testdata/csv.tmx", "testdata/xml.tmx", "testdata/csv.tmj"}
 
 	layer0Data = []GID{
 		1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 7, 8, 7, 8, 7, 8, 7, 8, 7, 8, 7, 8, 7, 8, 7, 8, 7, 8,
@@ -129,3 +136,220 @@ func TestProperties(t *testing.T) {
 	t.Fatal("No property found")
 
 }
+
+func TestExternalTileset(t *testing.T) {
+	m, err := ReadFile("testdata/external.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := &m.Tilesets[0]
+	if ts.FirstGID != 1 || ts.Source != "tilesets/default.tsx" {
+		t.Error("FirstGID or Source not kept:", ts.FirstGID, ts.Source)
+	}
+	if ts.Name != "default" || ts.TileWidth != 8 || ts.Image.Source != "../tiles.png" {
+		t.Error("Tileset not loaded:", ts.Name, ts.TileWidth, ts.Image.Source)
+	}
+
+	if m.Layers[0].DecodedTiles()[0].Tileset != ts {
+		t.Error("Decoded tile does not point to the loaded tileset")
+	}
+}
+
+func TestExternalTilesetMissing(t *testing.T) {
+	const missing = `<map width="1" height="1"><tileset firstgid="1" source="nosuchfile.tsx"/></map>`
+	_, err := Read(strings.NewReader(missing))
+	if err == nil || !strings.Contains(err.Error(), "nosuchfile.tsx") {
+		t.Error("Error does not name the missing tileset:", err)
+	}
+}
+
+func TestInfinite(t *testing.T) {
+	m, err := ReadFile("testdata/infinite.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !m.Infinite {
+		t.Error("Map not infinite")
+	}
+
+	cells := []struct {
+		x, y int
+		gid  GID
+	}{
+		{-4, -4, 1}, {-1, -1, 16}, {-3, -2, 10}, {0, 0, 0}, {1, 1, 1}, {3, 3, 11}, {5, -4, 8}, {6, -1, 0}, {100, 100, 0}, {-5, 0, 0},
+	}
+
+	for _, l := range m.Layers {
+		if b := l.Bounds(); b != image.Rect(-4, -4, 8, 4) {
+			t.Error(l.Name, "wrong bounds", b)
+		}
+
+		for _, c := range cells {
+			tile := l.TileAt(c.x, c.y)
+			if c.gid == 0 {
+				if !tile.IsNil() {
+					t.Error(l.Name, "expected no tile at", c.x, c.y)
+				}
+				continue
+			}
+			if tile.IsNil() || tile.ID != ID(c.gid-1) {
+				t.Error(l.Name, "wrong tile at", c.x, c.y, tile.ID)
+			}
+		}
+	}
+}
+
+// Returns a size x size map with the given number of base64-zlib layers, using tiles of 8 tilesets.
+func benchmarkMap(size, layers int) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<map version="1.0" orientation="orthogonal" width="%d" height="%d" tilewidth="8" tileheight="8">`, size, size)
+	for i := 0; i < 8; i++ {
+		fmt.Fprintf(&b, `<tileset firstgid="%d" name="ts%d" tilewidth="8" tileheight="8" tilecount="28" columns="14"/>`, 1+28*i, i)
+	}
+
+	gids := make([]GID, size*size)
+	for i := range gids {
+		gids[i] = GID(i % (28*8 + 1))
+		if i%7 == 0 && gids[i] != 0 {
+			gids[i] |= GIDHorizontalFlip
+		}
+	}
+	var data bytes.Buffer
+	encw := base64.NewEncoder(base64.StdEncoding, &data)
+	comw := zlib.NewWriter(encw)
+	binary.Write(comw, binary.LittleEndian, gids)
+	comw.Close()
+	encw.Close()
+
+	for i := 0; i < layers; i++ {
+		fmt.Fprintf(&b, `<layer name="l%d" width="%d" height="%d"><data encoding="base64" compression="zlib">%s</data></layer>`, i, size, size, data.Bytes())
+	}
+	b.WriteString(`</map>`)
+	return b.Bytes()
+}
+
+func benchmarkRead(b *testing.B, size, layers, workers int) {
+	data := benchmarkMap(size, layers)
+	l := &Loader{Workers: workers}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := l.read(bytes.NewReader(data), ""); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRead256(b *testing.B)         { benchmarkRead(b, 256, 6, 0) }
+func BenchmarkRead1024(b *testing.B)        { benchmarkRead(b, 1024, 6, 0) }
+func BenchmarkRead1024Workers(b *testing.B) { benchmarkRead(b, 1024, 6, -1) }
+
+func TestMapAttributes(t *testing.T) {
+	for _, name := range []string{"testdata/hexagonal.tmx", "testdata/hexagonal.tmj"} {
+		m, err := ReadFile(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		if m.Version != "1.10" || m.TiledVersion != "1.10.2" || m.Class != "level" || m.Orientation != "hexagonal" || m.RenderOrder != "left-up" {
+			t.Error(name, "wrong version, class, orientation or render order:", m.Version, m.TiledVersion, m.Class, m.Orientation, m.RenderOrder)
+		}
+		if m.HexSideLength != 6 || m.StaggerAxis != "y" || m.StaggerIndex != "odd" {
+			t.Error(name, "wrong hexagonal attributes:", m.HexSideLength, m.StaggerAxis, m.StaggerIndex)
+		}
+		if m.ParallaxOriginX != 10 || m.ParallaxOriginY != -5 || m.BackgroundColor != "#ff2a3c4d" {
+			t.Error(name, "wrong parallax origin or background color:", m.ParallaxOriginX, m.ParallaxOriginY, m.BackgroundColor)
+		}
+		if m.NextLayerID != 6 || m.NextObjectID != 3 {
+			t.Error(name, "wrong next IDs:", m.NextLayerID, m.NextObjectID)
+		}
+
+		layers := []struct {
+			name    string
+			visible bool
+			opacity float32
+		}{
+			{"Ground", true, 1}, {"Decor", true, 0.5}, {"Decor/Hidden", false, 1}, {"Objects", true, 1}, {"Sky", true, 0.75},
+		}
+		for _, l := range layers {
+			n := m.LayerByPath(l.name)
+			if n == nil {
+				t.Error(name, "no layer", l.name)
+				continue
+			}
+			if b := n.Base(); b.Visible != l.visible || b.Opacity != l.opacity {
+				t.Error(name, l.name, "wrong visibility or opacity:", b.Visible, b.Opacity)
+			}
+		}
+
+		if o := m.ObjectByID(1); o == nil || !o.Visible {
+			t.Error(name, "object 1 not visible")
+		}
+		if o := m.ObjectByID(2); o == nil || o.Visible {
+			t.Error(name, "object 2 visible")
+		}
+	}
+}
+
+func TestMapDefaults(t *testing.T) {
+	const tmx = `<map width="1" height="1">
+ <layer name="l"><data encoding="csv">0</data></layer>
+ <group name="g"><objectgroup name="o"><object id="1"/></objectgroup></group>
+ <imagelayer name="i"/>
+</map>`
+	m, err := Read(strings.NewReader(tmx))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.RenderOrder != "right-down" {
+		t.Error("Wrong default render order", m.RenderOrder)
+	}
+	m.walkLayers(func(n *LayerNode) {
+		if b := n.Base(); !b.Visible || b.Opacity != 1 {
+			t.Error("Wrong default visibility or opacity of layer", b.Name, b.Visible, b.Opacity)
+		}
+	})
+	if !m.ObjectGroups[0].Objects[0].Visible {
+		t.Error("Object not visible by default")
+	}
+}
+
+func TestTiledDefaults(t *testing.T) {
+	// These maps were saved by an old version of Tiled, which leaves out all attributes added since, along with
+	// those that have their default value.
+	for _, name := range []string{"testdata/poly.tmx", "testdata/base64-gzip.tmx"} {
+		m, err := ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m.RenderOrder != "right-down" || m.Infinite || m.Class != "" || m.BackgroundColor != "" {
+			t.Errorf("%s: wrong map defaults %+v", name, *m)
+		}
+
+		ts := &m.Tilesets[0]
+		if ts.ObjectAlignment != "unspecified" || ts.TileRenderSize != "tile" || ts.FillMode != "stretch" ||
+			ts.Grid.Orientation != "orthogonal" || ts.TileOffset != (TileOffset{}) || ts.Transformations != (Transformations{}) {
+			t.Errorf("%s: wrong tileset defaults %+v", name, *ts)
+		}
+
+		m.walkLayers(func(n *LayerNode) {
+			b := n.Base()
+			if !b.Visible || b.Opacity != 1 || b.Locked || b.OffsetX != 0 || b.OffsetY != 0 || b.ParallaxX != 1 || b.ParallaxY != 1 {
+				t.Errorf("%s: wrong defaults of layer %q: %+v", name, b.Name, *b)
+			}
+		})
+
+		for i := range m.ObjectGroups {
+			for _, o := range m.ObjectGroups[i].Objects {
+				if !o.Visible || o.Rotation != 0 || o.Shape() != ShapePolyline {
+					t.Errorf("%s: wrong object defaults %+v", name, o)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,274 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"encoding/xml"
+	"strconv"
+	"strings"
+)
+
+// A set of colors tiles are painted with at their corners and edges, used by Tiled's terrain tools.
+// Wang sets made in Go start from NewWangSet, and their colors from NewWangColor; a zero WangColor is never picked.
+type WangSet struct {
+	Name       string      `xml:"name,attr"`
+	Class      string      `xml:"class,attr"`
+	Type       string      `xml:"type,attr"` // corner, edge or mixed: what parts of tiles have colors.
+	Tile       int         `xml:"tile,attr"` // ID of the tile representing the set, -1 if none.
+	Properties Properties  `xml:"properties>property"`
+	Colors     []WangColor `xml:"wangcolor"` // Color i of a WangID is Colors[i-1].
+	Tiles      []WangTile  `xml:"wangtile"`
+}
+
+type WangColor struct {
+	Name        string     `xml:"name,attr"`
+	Class       string     `xml:"class,attr"`
+	Color       string     `xml:"color,attr"`       // As #RRGGBB, shown in Tiled.
+	Tile        int        `xml:"tile,attr"`        // ID of the tile representing the color, -1 if none.
+	Probability float64    `xml:"probability,attr"` // Relative chance of the color being picked, 1 by default.
+	Properties  Properties `xml:"properties>property"`
+}
+
+// The colors of a tile of a wang set.
+type WangTile struct {
+	TileID ID     `xml:"tileid,attr" json:"tileid"`
+	WangID WangID `xml:"wangid,attr" json:"wangid"`
+}
+
+// The colors of the edges and corners of a tile, clockwise from its top edge; see WangTop and the
+// constants following it. Color 0 is no color, others index WangSet.Colors from 1.
+type WangID [8]uint8
+
+// Indices of WangID.
+const (
+	WangTop = iota
+	WangTopRight
+	WangRight
+	WangBottomRight
+	WangBottom
+	WangBottomLeft
+	WangLeft
+	WangTopLeft
+)
+
+// A color of patterns matching any color; see WangID.Matches.
+const WangAny = 0xff
+
+func (ws *WangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type Attrs WangSet // Has no UnmarshalXML method, avoiding recursion. Exported, so that it is decoded when embedded.
+	v := struct {
+		*Attrs
+		EdgeColors   []WangColor `xml:"wangedgecolor"`   // Replaced by wangcolor in Tiled 1.5.
+		CornerColors []WangColor `xml:"wangcornercolor"` // Replaced by wangcolor in Tiled 1.5.
+	}{Attrs: (*Attrs)(ws)}
+
+	ws.Tile = -1 // Default, omitted by older versions of Tiled.
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	ws.convertColors(v.EdgeColors, v.CornerColors)
+	return nil
+}
+
+func (c *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type wangColor WangColor // Has no UnmarshalXML method, avoiding recursion.
+	v := (*wangColor)(c)
+	v.Tile, v.Probability = -1, 1 // Defaults, omitted by Tiled.
+	return d.DecodeElement(v, &start)
+}
+
+// Returns a wang set of the given name and type, with the defaults of TMX files.
+func NewWangSet(name, typ string) WangSet {
+	return WangSet{Name: name, Type: typ, Tile: -1}
+}
+
+// Returns a wang color of the given name and color, as #RRGGBB, with the defaults of TMX files.
+func NewWangColor(name, color string) WangColor {
+	return WangColor{Name: name, Color: color, Tile: -1, Probability: 1}
+}
+
+// Parses the comma separated form of TMX files, or the hexadecimal one of Tiled before 1.5, which holds
+// color i of the ID in bits 4i to 4i+3.
+func (id *WangID) UnmarshalXMLAttr(a xml.Attr) error {
+	if hex := strings.TrimPrefix(a.Value, "0x"); hex != a.Value {
+		v, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return InvalidWangID
+		}
+		for i := range id {
+			id[i] = uint8(v >> (4 * i) & 0xf)
+		}
+		return nil
+	}
+
+	colors := strings.Split(a.Value, ",")
+	if len(colors) != len(id) {
+		return InvalidWangID
+	}
+	for i, s := range colors {
+		c, err := strconv.ParseUint(strings.TrimSpace(s), 10, 8)
+		if err != nil {
+			return InvalidWangID
+		}
+		id[i] = uint8(c)
+	}
+	return nil
+}
+
+// Returns the comma separated form of TMX files.
+func (id WangID) String() string {
+	b := make([]byte, 0, 2*len(id))
+	for i, c := range id {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(c), 10)
+	}
+	return string(b)
+}
+
+// Returns a pattern of the given corner colors, matching tiles of any edge colors.
+func CornerPattern(topRight, bottomRight, bottomLeft, topLeft uint8) WangID {
+	return WangID{WangAny, topRight, WangAny, bottomRight, WangAny, bottomLeft, WangAny, topLeft}
+}
+
+// Returns a pattern of the given edge colors, matching tiles of any corner colors.
+func EdgePattern(top, right, bottom, left uint8) WangID {
+	return WangID{top, WangAny, right, WangAny, bottom, WangAny, left, WangAny}
+}
+
+// Reports whether id has the colors of pattern, where pattern is not WangAny.
+func (id WangID) Matches(pattern WangID) bool {
+	for i, c := range pattern {
+		if c != WangAny && id[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns the tileset's wang set with the given name, or nil if there is none.
+func (ts *Tileset) WangSet(name string) *WangSet {
+	for i := 0; i < len(ts.WangSets); i++ {
+		if ts.WangSets[i].Name == name {
+			return &ts.WangSets[i]
+		}
+	}
+	return nil
+}
+
+// Returns the color with the given index of WangID, or nil for 0 and indices out of range.
+func (ws *WangSet) Color(c uint8) *WangColor {
+	if c == 0 || int(c) > len(ws.Colors) {
+		return nil
+	}
+	return &ws.Colors[c-1]
+}
+
+// Returns the colors of the tile with the given ID, and false if it is not part of the set.
+func (ws *WangSet) WangID(tile ID) (WangID, bool) {
+	for i := 0; i < len(ws.Tiles); i++ {
+		if ws.Tiles[i].TileID == tile {
+			return ws.Tiles[i].WangID, true
+		}
+	}
+	return WangID{}, false
+}
+
+// Returns the tiles of the set whose colors match pattern, in the order of Tiles; see WangID.Matches,
+// CornerPattern and EdgePattern.
+func (ws *WangSet) Matching(pattern WangID) []WangTile {
+	var tiles []WangTile
+	for _, t := range ws.Tiles {
+		if t.WangID.Matches(pattern) {
+			tiles = append(tiles, t)
+		}
+	}
+	return tiles
+}
+
+// Converts the separate edge and corner colors of wang sets of Tiled before 1.5 into Colors, the edge colors
+// first, so that the corner colors of Tiles, which count from 1 as the edge ones do, are shifted past them.
+// Type, which those sets lack, is told by the kinds of colors.
+func (ws *WangSet) convertColors(edges, corners []WangColor) {
+	if len(edges) == 0 && len(corners) == 0 || len(edges)+len(corners) >= WangAny {
+		return
+	}
+
+	switch {
+	case len(corners) == 0:
+		ws.Type = "edge"
+	case len(edges) == 0:
+		ws.Type = "corner"
+	default:
+		ws.Type = "mixed"
+	}
+
+	ws.Colors = append(append(ws.Colors, edges...), corners...)
+	for i := 0; i < len(ws.Tiles); i++ {
+		id := &ws.Tiles[i].WangID
+		for j := WangTopRight; j < len(id); j += 2 {
+			if id[j] != 0 {
+				id[j] += uint8(len(edges))
+			}
+		}
+	}
+}
+
+// Parses the terrain attribute of tiles of Tiled before 1.5: the terrain types of the top left, top right,
+// bottom left and bottom right corners, an empty entry being none. Malformed entries count as none.
+func parseTerrain(s string) []int {
+	terrain := []int{-1, -1, -1, -1}
+	for i, f := range strings.SplitN(s, ",", len(terrain)) {
+		if n, err := strconv.Atoi(strings.TrimSpace(f)); err == nil {
+			terrain[i] = n
+		}
+	}
+	return terrain
+}
+
+// Converts the terrain types and tile terrains of Tiled before 1.5 into a corner wang set, as Tiled does on
+// loading such tilesets. Terrain type i becomes color i+1.
+func (ts *Tileset) convertTerrains(terrains []WangColor) {
+	if len(terrains) == 0 || len(terrains) >= WangAny {
+		return
+	}
+
+	ws := WangSet{Name: "Terrains", Type: "corner", Tile: -1, Colors: terrains}
+	for i := 0; i < len(ts.Tiles); i++ {
+		t := &ts.Tiles[i]
+
+		var id WangID
+		for j, corner := range []int{WangTopLeft, WangTopRight, WangBottomLeft, WangBottomRight} {
+			if j < len(t.terrain) && t.terrain[j] >= 0 && t.terrain[j] < len(terrains) {
+				id[corner] = uint8(t.terrain[j] + 1)
+			}
+		}
+		t.terrain = nil
+
+		if id != (WangID{}) {
+			ws.Tiles = append(ws.Tiles, WangTile{t.ID, id})
+		}
+	}
+	ts.WangSets = append(ts.WangSets, ws)
+}
@@ -0,0 +1,237 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+)
+
+func matchingIDs(ws *WangSet, pattern WangID) []ID {
+	var ids []ID
+	for _, t := range ws.Matching(pattern) {
+		ids = append(ids, t.TileID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Reports the differences of the wang sets of two tilesets.
+func compareWangSets(t *testing.T, name string, ts, ts2 *Tileset) {
+	if len(ts2.WangSets) != len(ts.WangSets) {
+		t.Error(name, "wrong number of wang sets")
+		return
+	}
+	for i := range ts.WangSets {
+		ws, ws2 := &ts.WangSets[i], &ts2.WangSets[i]
+		if ws2.Name != ws.Name || ws2.Class != ws.Class || ws2.Type != ws.Type || ws2.Tile != ws.Tile ||
+			len(ws2.Properties) != len(ws.Properties) || len(ws2.Colors) != len(ws.Colors) || len(ws2.Tiles) != len(ws.Tiles) {
+			t.Errorf("%s: wang set %q differs: %+v", name, ws.Name, *ws2)
+			continue
+		}
+		for j := range ws.Colors {
+			c, c2 := &ws.Colors[j], &ws2.Colors[j]
+			if c2.Name != c.Name || c2.Class != c.Class || c2.Color != c.Color || c2.Tile != c.Tile ||
+				c2.Probability != c.Probability || len(c2.Properties) != len(c.Properties) {
+				t.Errorf("%s: wang color %q differs: %+v", name, c.Name, *c2)
+			}
+		}
+		for j := range ws.Tiles {
+			if ws2.Tiles[j] != ws.Tiles[j] {
+				t.Error(name, "wang tile differs", ws2.Tiles[j])
+			}
+		}
+	}
+}
+
+func TestWangSets(t *testing.T) {
+	for _, name := range []string{"testdata/tilesets/wang.tsx", "testdata/tilesets/wang.tsj"} {
+		ts := readTestTileset(t, name)
+
+		ground, roads := ts.WangSet("Ground"), ts.WangSet("Roads")
+		if ground == nil || roads == nil || ts.WangSet("Water") != nil {
+			t.Fatal(name, "wang sets not found")
+		}
+		if ground.Type != "corner" || ground.Tile != 0 || roads.Type != "edge" || roads.Tile != -1 {
+			t.Error(name, "wrong wang set attributes", ground.Type, ground.Tile, roads.Type, roads.Tile)
+		}
+		if p := ground.Properties.Get("layer"); p == nil || p.Value != "ground" {
+			t.Error(name, "wrong wang set property", p)
+		}
+
+		sand := ground.Color(2)
+		if sand == nil || sand.Name != "Sand" || sand.Class != "soft" || sand.Color != "#ffff00" || sand.Tile != 1 || sand.Probability != 0.5 {
+			t.Fatal(name, "wrong wang color", sand)
+		}
+		if speed, err := sand.Properties.Float("speed"); speed != 0.5 || err != nil {
+			t.Error(name, "wrong wang color property", speed, err)
+		}
+		if ground.Color(0) != nil || ground.Color(3) != nil {
+			t.Error(name, "color out of range found")
+		}
+
+		if id, ok := ground.WangID(2); !ok || id != (WangID{0, 2, 0, 1, 0, 1, 0, 2}) {
+			t.Error(name, "wrong wang ID", id, ok)
+		}
+		if _, ok := ground.WangID(5); ok {
+			t.Error(name, "wang ID of a tile not in the set")
+		}
+
+		patterns := []struct {
+			ws      *WangSet
+			pattern WangID
+			tiles   []ID
+		}{
+			{ground, CornerPattern(1, 1, 1, 1), []ID{0}},
+			{ground, CornerPattern(2, 1, 1, 2), []ID{2}},
+			{ground, CornerPattern(WangAny, 1, 1, WangAny), []ID{0, 2, 4}},
+			{ground, CornerPattern(WangAny, WangAny, 2, 2), []ID{1, 3}},
+			{ground, CornerPattern(1, 2, 1, 2), nil},
+			{roads, EdgePattern(0, 1, 0, 1), []ID{14}},
+			{roads, EdgePattern(1, WangAny, WangAny, WangAny), []ID{15}},
+		}
+		for _, p := range patterns {
+			if ids := matchingIDs(p.ws, p.pattern); !equalIDs(ids, p.tiles) {
+				t.Error(name, "wrong tiles matching", p.pattern, ids)
+			}
+		}
+	}
+}
+
+func TestLegacyTerrain(t *testing.T) {
+	for _, name := range []string{"testdata/tilesets/terrain.tsx", "testdata/tilesets/terrain.tsj"} {
+		ts := readTestTileset(t, name)
+
+		if len(ts.WangSets) != 1 {
+			t.Fatal(name, "terrains not converted")
+		}
+		ws := &ts.WangSets[0]
+		if ws.Type != "corner" || len(ws.Colors) != 2 || ws.Colors[0].Name != "Grass" || ws.Colors[1].Tile != 1 || len(ws.Colors[1].Properties) != 1 {
+			t.Errorf("%s: wrong wang set %+v", name, *ws)
+		}
+
+		want := []WangTile{
+			{0, WangID{0, 1, 0, 1, 0, 1, 0, 1}},
+			{1, WangID{0, 2, 0, 2, 0, 2, 0, 2}},
+			{2, WangID{0, 2, 0, 1, 0, 1, 0, 2}},
+			{5, WangID{0, 0, 0, 2, 0, 0, 0, 0}},
+		}
+		if len(ws.Tiles) != len(want) {
+			t.Fatal(name, "wrong wang tiles", ws.Tiles)
+		}
+		for i := range want {
+			if ws.Tiles[i] != want[i] {
+				t.Error(name, "wrong wang tile", ws.Tiles[i])
+			}
+		}
+	}
+}
+
+func TestLegacyWangSets(t *testing.T) {
+	want := readTestTileset(t, "testdata/tilesets/wang.tsx").WangSet("Ground")
+
+	for _, name := range []string{"testdata/tilesets/wang-legacy.tsx", "testdata/tilesets/wang-legacy.tsj"} {
+		ts := readTestTileset(t, name)
+
+		ground, paths := ts.WangSet("Ground"), ts.WangSet("Paths")
+		if ground == nil || paths == nil {
+			t.Fatal(name, "wang sets not found")
+		}
+		if ground.Type != "corner" || ground.Tile != 0 || len(ground.Colors) != 2 || ground.Colors[1].Name != "Sand" || ground.Colors[1].Probability != 0.5 {
+			t.Errorf("%s: wrong wang set %+v", name, *ground)
+		}
+		if len(ground.Tiles) != len(want.Tiles) {
+			t.Fatal(name, "wrong wang tiles", ground.Tiles)
+		}
+		for i := range want.Tiles {
+			if ground.Tiles[i] != want.Tiles[i] {
+				t.Error(name, "wrong wang tile", ground.Tiles[i])
+			}
+		}
+
+		// Corner colors follow the edge ones.
+		if paths.Type != "mixed" || len(paths.Colors) != 2 || paths.Colors[0].Name != "Road" || paths.Colors[1].Name != "Grass" {
+			t.Errorf("%s: wrong wang set %+v", name, *paths)
+		}
+		if id, _ := paths.WangID(14); id != (WangID{0, 2, 1, 2, 0, 2, 1, 2}) {
+			t.Error(name, "wrong wang ID", id)
+		}
+		if id, _ := paths.WangID(15); id != (WangID{1, 2, 0, 2, 1, 2, 0, 2}) {
+			t.Error(name, "wrong wang ID", id)
+		}
+	}
+}
+
+func TestWangIDAttr(t *testing.T) {
+	var wt WangTile
+	if err := xml.Unmarshal([]byte(`<wangtile tileid="3" wangid="1,0,2,0,3,0,4,255"/>`), &wt); err != nil {
+		t.Fatal(err)
+	}
+	if wt.TileID != 3 || wt.WangID != (WangID{1, 0, 2, 0, 3, 0, 4, 255}) || wt.WangID.String() != "1,0,2,0,3,0,4,255" {
+		t.Error("Wrong wang tile", wt)
+	}
+
+	if err := xml.Unmarshal([]byte(`<wangtile tileid="3" wangid="0x2010f001"/>`), &wt); err != nil || wt.WangID != (WangID{1, 0, 0, 15, 0, 1, 0, 2}) {
+		t.Error("Wrong legacy wang ID", wt.WangID, err)
+	}
+
+	for _, s := range []string{"1,2,3", "1,2,3,4,5,6,7,256", "0x", "0x123456789", "0x1g", "1,2,3,4,5,6,7,"} {
+		if err := xml.Unmarshal([]byte(`<wangtile wangid="`+s+`"/>`), &wt); !errors.Is(err, InvalidWangID) {
+			t.Error(s, "wrong error", err)
+		}
+	}
+}
+
+func TestWriteWangSets(t *testing.T) {
+	for _, name := range []string{"testdata/tilesets/wang.tsx", "testdata/tilesets/terrain.tsx", "testdata/tilesets/wang-legacy.tsx"} {
+		ts := readTestTileset(t, name)
+
+		for _, write := range []func(*bytes.Buffer) error{
+			func(b *bytes.Buffer) error { return ts.Write(b) },
+			func(b *bytes.Buffer) error { return ts.WriteJSON(b) },
+		} {
+			var buf bytes.Buffer
+			if err := write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			ts2, err := ReadTileset(&buf)
+			if err != nil {
+				t.Fatal(name, err)
+			}
+			compareWangSets(t, name, ts, ts2)
+		}
+	}
+}
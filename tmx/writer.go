@@ -0,0 +1,593 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Returns the GID the tile is stored as in a layer, flip bits included.
+func (t *DecodedTile) GID() GID {
+	if t.Nil || t.Tileset == nil {
+		return 0
+	}
+
+	gid := t.Tileset.FirstGID + GID(t.ID)
+	if t.HorizontalFlip {
+		gid |= GIDHorizontalFlip
+	}
+	if t.VerticalFlip {
+		gid |= GIDVerticalFlip
+	}
+	if t.DiagonalFlip {
+		gid |= GIDDiagonalFlip
+	}
+	return gid
+}
+
+// Writes the map as TMX. Layers are written from Layers, ObjectGroups and ImageLayers, in the order and groups
+// of LayerTree; layers only in those slices, as in maps made in Go, follow the others at the top level.
+// Tile layers are written from their GIDs, using the encoding and compression given by their Data.
+// Tilesets that have a Source are written as references only; see Tileset.Write. Attributes are written as
+// they are, so zero values of layers and objects made in Go make them invisible; see NewLayerBase and NewObject.
+func (m *Map) Write(w io.Writer) error {
+	e := newEncoder(w)
+
+	e.start("map",
+		attr("version", m.Version),
+		attr("tiledversion", m.TiledVersion),
+		attr("class", m.Class),
+		attr("orientation", m.Orientation),
+		attr("renderorder", m.RenderOrder),
+		intAttr("width", m.Width),
+		intAttr("height", m.Height),
+		intAttr("tilewidth", m.TileWidth),
+		intAttr("tileheight", m.TileHeight),
+		boolAttr("infinite", m.Infinite, false),
+		intAttr("hexsidelength", m.HexSideLength),
+		attr("staggeraxis", m.StaggerAxis),
+		attr("staggerindex", m.StaggerIndex),
+		floatAttr("parallaxoriginx", m.ParallaxOriginX, 0),
+		floatAttr("parallaxoriginy", m.ParallaxOriginY, 0),
+		attr("backgroundcolor", m.BackgroundColor),
+		intAttr("nextlayerid", int(m.NextLayerID)),
+		intAttr("nextobjectid", int(m.NextObjectID)),
+	)
+	e.properties(m.Properties)
+	for i := 0; i < len(m.Tilesets); i++ {
+		e.tileset(&m.Tilesets[i], true)
+	}
+	e.layers(m, m.writtenLayers())
+	e.end("map")
+
+	return e.flush()
+}
+
+// Writes the map as TMX to the named file, creating or truncating it.
+func (m *Map) WriteFile(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Writes the tileset as an external tileset (a TSX file).
+func (ts *Tileset) Write(w io.Writer) error {
+	e := newEncoder(w)
+	e.tileset(ts, false)
+	return e.flush()
+}
+
+// An xml.Encoder that remembers the first error, so that elements can be written without checking each one.
+type encoder struct {
+	w   io.Writer
+	e   *xml.Encoder
+	err error
+}
+
+func newEncoder(w io.Writer) *encoder {
+	e := &encoder{w: w, e: xml.NewEncoder(w)}
+	e.e.Indent("", " ")
+	_, e.err = io.WriteString(w, xml.Header)
+	return e
+}
+
+func (e *encoder) token(t xml.Token) {
+	if e.err == nil {
+		e.err = e.e.EncodeToken(t)
+	}
+}
+
+// Attributes with an empty name are left out; see attr.
+func (e *encoder) start(name string, attrs ...xml.Attr) {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	for _, a := range attrs {
+		if a.Name.Local != "" {
+			start.Attr = append(start.Attr, a)
+		}
+	}
+	e.token(start)
+}
+
+func (e *encoder) end(name string) {
+	e.token(xml.EndElement{Name: xml.Name{Local: name}})
+}
+
+func (e *encoder) empty(name string, attrs ...xml.Attr) {
+	e.start(name, attrs...)
+	e.end(name)
+}
+
+func (e *encoder) text(s string) {
+	e.token(xml.CharData(s))
+}
+
+func (e *encoder) flush() error {
+	if e.err == nil {
+		e.err = e.e.Flush()
+	}
+	if e.err == nil {
+		_, e.err = io.WriteString(e.w, "\n")
+	}
+	return e.err
+}
+
+// Returns an attribute, or an attribute with no name, which is not written, if the value is empty.
+func attr(name, value string) xml.Attr {
+	if value == "" {
+		return xml.Attr{}
+	}
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+func stringAttr(name, v, def string) xml.Attr {
+	if v == def {
+		return xml.Attr{}
+	}
+	return attr(name, v)
+}
+
+// Zero is omitted, as for all attributes that default to zero.
+func intAttr(name string, v int) xml.Attr {
+	if v == 0 {
+		return xml.Attr{}
+	}
+	return attr(name, strconv.Itoa(v))
+}
+
+func floatAttr(name string, v, def float64) xml.Attr {
+	if v == def {
+		return xml.Attr{}
+	}
+	return attr(name, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func boolAttr(name string, v, def bool) xml.Attr {
+	if v == def {
+		return xml.Attr{}
+	}
+	if v {
+		return attr(name, "1")
+	}
+	return attr(name, "0")
+}
+
+func (e *encoder) properties(ps Properties) {
+	if len(ps) == 0 {
+		return
+	}
+
+	e.start("properties")
+	for i := 0; i < len(ps); i++ {
+		p := &ps[i]
+		typ := p.Type
+		if typ == "string" {
+			typ = ""
+		}
+		attrs := []xml.Attr{attr("name", p.Name), attr("type", typ), attr("propertytype", p.PropertyType)}
+
+		switch {
+		case len(p.Properties) > 0:
+			e.start("property", attrs...)
+			e.properties(p.Properties)
+			e.end("property")
+		case strings.Contains(p.Value, "\n"):
+			e.start("property", attrs...)
+			e.text(p.Value)
+			e.end("property")
+		default:
+			if p.Type != "class" {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "value"}, Value: p.Value})
+			}
+			e.empty("property", attrs...)
+		}
+	}
+	e.end("properties")
+}
+
+func (e *encoder) image(img *Image) {
+	if img.Source == "" {
+		return
+	}
+	e.empty("image",
+		attr("source", img.Source),
+		attr("trans", img.Trans),
+		intAttr("width", img.Width),
+		intAttr("height", img.Height),
+	)
+}
+
+// Writes a tileset; inMap tells whether it is part of a map, or an external tileset of its own.
+func (e *encoder) tileset(ts *Tileset, inMap bool) {
+	var firstGID xml.Attr
+	if inMap {
+		firstGID = attr("firstgid", strconv.FormatUint(uint64(ts.FirstGID), 10))
+		if ts.Source != "" {
+			e.empty("tileset", firstGID, attr("source", ts.Source))
+			return
+		}
+	}
+
+	e.start("tileset",
+		firstGID,
+		attr("name", ts.Name),
+		attr("class", ts.Class),
+		intAttr("tilewidth", ts.TileWidth),
+		intAttr("tileheight", ts.TileHeight),
+		intAttr("spacing", ts.Spacing),
+		intAttr("margin", ts.Margin),
+		intAttr("tilecount", ts.Tilecount),
+		intAttr("columns", ts.Columns),
+		stringAttr("objectalignment", ts.ObjectAlignment, "unspecified"),
+		stringAttr("tilerendersize", ts.TileRenderSize, "tile"),
+		stringAttr("fillmode", ts.FillMode, "stretch"),
+	)
+	if o := ts.TileOffset; o != (TileOffset{}) {
+		e.empty("tileoffset", attr("x", strconv.Itoa(o.X)), attr("y", strconv.Itoa(o.Y)))
+	}
+	if g := ts.Grid; g.Width != 0 || g.Height != 0 || g.Orientation != "" && g.Orientation != "orthogonal" {
+		e.empty("grid", attr("orientation", g.Orientation), attr("width", strconv.Itoa(g.Width)), attr("height", strconv.Itoa(g.Height)))
+	}
+	if tr := ts.Transformations; tr != (Transformations{}) {
+		e.empty("transformations",
+			boolAttr("hflip", tr.HFlip, false),
+			boolAttr("vflip", tr.VFlip, false),
+			boolAttr("rotate", tr.Rotate, false),
+			boolAttr("preferuntransformed", tr.PreferUntransformed, false),
+		)
+	}
+	e.properties(ts.Properties)
+	e.image(&ts.Image)
+
+	for i := 0; i < len(ts.Tiles); i++ {
+		t := &ts.Tiles[i]
+		e.start("tile",
+			attr("id", strconv.FormatUint(uint64(t.ID), 10)),
+			attr("type", t.Type),
+			floatAttr("probability", t.Probability, 1),
+			intAttr("x", t.X),
+			intAttr("y", t.Y),
+			intAttr("width", t.Width),
+			intAttr("height", t.Height),
+		)
+		e.properties(t.Properties)
+		e.image(&t.Image)
+		if t.ObjectGroup != nil {
+			e.objectGroup(t.ObjectGroup)
+		}
+		if len(t.Animation) > 0 {
+			e.start("animation")
+			for _, f := range t.Animation {
+				e.empty("frame",
+					attr("tileid", strconv.FormatUint(uint64(f.TileID), 10)),
+					attr("duration", strconv.Itoa(f.Duration)),
+				)
+			}
+			e.end("animation")
+		}
+		e.end("tile")
+	}
+
+	if len(ts.WangSets) > 0 {
+		e.start("wangsets")
+		for i := 0; i < len(ts.WangSets); i++ {
+			e.wangSet(&ts.WangSets[i])
+		}
+		e.end("wangsets")
+	}
+
+	e.end("tileset")
+}
+
+func (e *encoder) wangSet(ws *WangSet) {
+	e.start("wangset",
+		attr("name", ws.Name),
+		attr("class", ws.Class),
+		attr("type", ws.Type),
+		attr("tile", strconv.Itoa(ws.Tile)),
+	)
+	e.properties(ws.Properties)
+	for i := 0; i < len(ws.Colors); i++ {
+		c := &ws.Colors[i]
+		e.start("wangcolor",
+			attr("name", c.Name),
+			attr("class", c.Class),
+			attr("color", c.Color),
+			attr("tile", strconv.Itoa(c.Tile)),
+			floatAttr("probability", c.Probability, 1),
+		)
+		e.properties(c.Properties)
+		e.end("wangcolor")
+	}
+	for _, t := range ws.Tiles {
+		e.empty("wangtile", attr("tileid", strconv.FormatUint(uint64(t.TileID), 10)), attr("wangid", t.WangID.String()))
+	}
+	e.end("wangset")
+}
+
+// Returns the attributes common to all layers, with extra ones following the name.
+func layerAttrs(b *LayerBase, extra ...xml.Attr) []xml.Attr {
+	attrs := []xml.Attr{intAttr("id", int(b.ID)), attr("name", b.Name), attr("class", b.Class)}
+	attrs = append(attrs, extra...)
+	return append(attrs,
+		floatAttr("opacity", float64(b.Opacity), 1),
+		boolAttr("visible", b.Visible, true),
+		boolAttr("locked", b.Locked, false),
+		attr("tintcolor", b.TintColor),
+		floatAttr("offsetx", b.OffsetX, 0),
+		floatAttr("offsety", b.OffsetY, 0),
+		floatAttr("parallaxx", b.ParallaxX, 1),
+		floatAttr("parallaxy", b.ParallaxY, 1),
+	)
+}
+
+func (e *encoder) layers(m *Map, nodes []LayerNode) {
+	for i := 0; i < len(nodes); i++ {
+		n := &nodes[i]
+		switch {
+		case n.Layer != nil:
+			e.layer(m, n.Layer)
+		case n.ObjectGroup != nil:
+			e.objectGroup(n.ObjectGroup)
+		case n.ImageLayer != nil:
+			l := n.ImageLayer
+			e.start("imagelayer", append(layerAttrs(&l.LayerBase),
+				boolAttr("repeatx", l.RepeatX, false),
+				boolAttr("repeaty", l.RepeatY, false),
+			)...)
+			e.properties(l.Properties)
+			e.image(&l.Image)
+			e.end("imagelayer")
+		case n.Group != nil:
+			e.start("group", layerAttrs(&n.Group.LayerBase)...)
+			e.properties(n.Group.Properties)
+			e.layers(m, n.Group.Children)
+			e.end("group")
+		}
+	}
+}
+
+func (e *encoder) layer(m *Map, l *Layer) {
+	width, height := l.Width, l.Height
+	if width == 0 && height == 0 {
+		width, height = m.Width, m.Height
+	}
+
+	e.start("layer", layerAttrs(&l.LayerBase, intAttr("width", width), intAttr("height", height))...)
+	e.properties(l.Properties)
+
+	e.start("data", attr("encoding", l.Data.Encoding), attr("compression", l.Data.Compression))
+	if m.Infinite {
+		for i := 0; i < len(l.Data.Chunks); i++ {
+			c := &l.Data.Chunks[i]
+			e.start("chunk",
+				attr("x", strconv.Itoa(c.X)),
+				attr("y", strconv.Itoa(c.Y)),
+				attr("width", strconv.Itoa(c.Width)),
+				attr("height", strconv.Itoa(c.Height)),
+			)
+			e.data(&l.Data, c.GIDs, c.Width)
+			e.end("chunk")
+		}
+	} else {
+		e.data(&l.Data, l.GIDs, width)
+	}
+	e.end("data")
+
+	e.end("layer")
+}
+
+// Writes the tiles of a layer or chunk, which is width tiles wide, as specified by d.
+func (e *encoder) data(d *Data, gids []GID, width int) {
+	if e.err != nil {
+		return
+	}
+
+	switch d.Encoding {
+	case "csv":
+		var b strings.Builder
+		b.WriteByte('\n')
+		for i, gid := range gids {
+			b.WriteString(strconv.FormatUint(uint64(gid), 10))
+			if i < len(gids)-1 {
+				b.WriteByte(',')
+			}
+			if width > 0 && (i+1)%width == 0 || i == len(gids)-1 {
+				b.WriteByte('\n')
+			}
+		}
+		e.text(b.String())
+	case "base64":
+		s, err := encodeBase64(gids, d.Compression)
+		if err != nil {
+			e.err = err
+			return
+		}
+		e.text("\n" + s + "\n")
+	case "":
+		for _, gid := range gids {
+			if gid == 0 {
+				e.empty("tile")
+			} else {
+				e.empty("tile", attr("gid", strconv.FormatUint(uint64(gid), 10)))
+			}
+		}
+	default:
+		var b []byte
+		if b, e.err = encodeCustom(gids, d.Encoding); e.err == nil {
+			e.text("\n" + string(b) + "\n")
+		}
+	}
+}
+
+func encodeBase64(gids []GID, compression string) (string, error) {
+	var buf bytes.Buffer
+	encw := base64.NewEncoder(base64.StdEncoding, &buf)
+
+	comw := io.WriteCloser(encw)
+	if compression != "" {
+		c, ok := lookupCompression(compression)
+		if !ok || c.compress == nil {
+			return "", UnknownCompression
+		}
+
+		var err error
+		if comw, err = c.compress(encw); err != nil {
+			return "", err
+		}
+	}
+
+	if err := binary.Write(comw, binary.LittleEndian, gids); err != nil {
+		return "", err
+	}
+	if comw != encw {
+		if err := comw.Close(); err != nil {
+			return "", err
+		}
+	}
+	if err := encw.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Encodes GIDs in an encoding registered with RegisterEncoding.
+func encodeCustom(gids []GID, name string) ([]byte, error) {
+	enc, ok := lookupEncoding(name)
+	if !ok || enc.encode == nil {
+		return nil, UnknownEncoding
+	}
+	return enc.encode(gids)
+}
+
+func (e *encoder) objectGroup(g *ObjectGroup) {
+	e.start("objectgroup", layerAttrs(&g.LayerBase, attr("color", g.Color))...)
+	e.properties(g.Properties)
+	for i := 0; i < len(g.Objects); i++ {
+		e.object(&g.Objects[i])
+	}
+	e.end("objectgroup")
+}
+
+func (e *encoder) object(o *Object) {
+	e.start("object",
+		intAttr("id", int(o.ID)),
+		attr("template", o.Template),
+		attr("name", o.Name),
+		attr("type", o.Type),
+		intAttr("gid", o.GID),
+		floatAttr("x", o.X, 0),
+		floatAttr("y", o.Y, 0),
+		floatAttr("width", o.Width, 0),
+		floatAttr("height", o.Height, 0),
+		floatAttr("rotation", o.Rotation, 0),
+		boolAttr("visible", o.Visible, true),
+	)
+	e.properties(o.Properties)
+
+	switch {
+	case o.Ellipse != nil:
+		e.empty("ellipse")
+	case o.Point != nil:
+		e.empty("point")
+	}
+	for _, p := range o.Polygons {
+		e.empty("polygon", attr("points", p.Points))
+	}
+	for _, p := range o.PolyLines {
+		e.empty("polyline", attr("points", p.Points))
+	}
+
+	if t := o.Text; t != nil {
+		fontFamily := t.FontFamily
+		if fontFamily == "sans-serif" {
+			fontFamily = ""
+		}
+		pixelSize := t.PixelSize
+		if pixelSize == 16 {
+			pixelSize = 0
+		}
+		color := t.Color
+		if color == "#000000" {
+			color = ""
+		}
+		halign, valign := t.HAlign, t.VAlign
+		if halign == "left" {
+			halign = ""
+		}
+		if valign == "top" {
+			valign = ""
+		}
+
+		e.start("text",
+			attr("fontfamily", fontFamily),
+			intAttr("pixelsize", pixelSize),
+			boolAttr("wrap", t.Wrap, false),
+			attr("color", color),
+			boolAttr("bold", t.Bold, false),
+			boolAttr("italic", t.Italic, false),
+			boolAttr("underline", t.Underline, false),
+			boolAttr("strikeout", t.Strikeout, false),
+			boolAttr("kerning", t.Kerning, true),
+			attr("halign", halign),
+			attr("valign", valign),
+		)
+		e.text(t.Text)
+		e.end("text")
+	}
+
+	e.end("object")
+}
@@ -0,0 +1,218 @@
+/*
+   Copyright (c) Utkan Güngördü <utkan@freeconsole.org>
+
+   This program is free software; you can redistribute it and/or modify
+   it under the terms of the GNU General Public License as
+   published by the Free Software Foundation; either version 3 or
+   (at your option) any later version.
+
+   This program is distributed in the hope that it will be useful,
+   but WITHOUT ANY WARRANTY; without even the implied warranty of
+
+   MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+
+   GNU General Public License for more details
+
+
+   You should have received a copy of the GNU General Public
+   License along with this program; if not, write to the
+   Free Software Foundation, Inc.,
+   51 Franklin Street, Fifth Floor, Boston, MA  02110-1301, USA.
+*/
+
+package tmx
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func layerGIDs(l *Layer) []GID {
+	gids := append([]GID(nil), l.GIDs...)
+	for _, c := range l.Data.Chunks {
+		gids = append(gids, c.GIDs...)
+	}
+	return gids
+}
+
+func equalGIDs(a, b []GID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Writes the map and reads it back, as if it was the file name.
+func rewrite(t *testing.T, m *Map, name string) *Map {
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(name, err)
+	}
+
+	m2, err := new(Loader).read(&buf, name)
+	if err != nil {
+		t.Fatal(name, err, buf.String())
+	}
+	return m2
+}
+
+// Reports the differences of a map and its rewritten version m2 that matter to the writers.
+func compareMaps(t *testing.T, name string, m, m2 *Map) {
+	if len(m2.Tilesets) != len(m.Tilesets) || len(m2.Layers) != len(m.Layers) ||
+		len(m2.ObjectGroups) != len(m.ObjectGroups) || len(m2.ImageLayers) != len(m.ImageLayers) {
+		t.Error(name, "wrong number of tilesets or layers")
+		return
+	}
+
+	if m2.Version != m.Version || m2.TiledVersion != m.TiledVersion || m2.Class != m.Class || m2.Orientation != m.Orientation ||
+		m2.RenderOrder != m.RenderOrder || m2.HexSideLength != m.HexSideLength || m2.StaggerAxis != m.StaggerAxis ||
+		m2.StaggerIndex != m.StaggerIndex || m2.ParallaxOriginX != m.ParallaxOriginX || m2.ParallaxOriginY != m.ParallaxOriginY ||
+		m2.BackgroundColor != m.BackgroundColor || m2.NextLayerID != m.NextLayerID || m2.NextObjectID != m.NextObjectID {
+		t.Error(name, "map attributes differ")
+	}
+
+	var bases []*LayerBase
+	m.walkLayers(func(n *LayerNode) { bases = append(bases, n.Base()) })
+	m2.walkLayers(func(n *LayerNode) {
+		if len(bases) == 0 {
+			return
+		}
+		b, want := n.Base(), bases[0]
+		bases = bases[1:]
+		if b.ID != want.ID || b.Class != want.Class || b.Visible != want.Visible || b.Locked != want.Locked || b.Opacity != want.Opacity ||
+			b.OffsetX != want.OffsetX || b.OffsetY != want.OffsetY || b.ParallaxX != want.ParallaxX || b.ParallaxY != want.ParallaxY ||
+			b.TintColor != want.TintColor {
+			t.Error(name, "attributes of layer", b.Name, "differ")
+		}
+	})
+
+	for i := range m.Layers {
+		if !equalGIDs(layerGIDs(&m.Layers[i]), layerGIDs(&m2.Layers[i])) {
+			t.Error(name, "layer", m.Layers[i].Name, "differs")
+		}
+	}
+
+	for i := range m.ObjectGroups {
+		objects, objects2 := m.ObjectGroups[i].Objects, m2.ObjectGroups[i].Objects
+		if len(objects) != len(objects2) {
+			t.Error(name, "wrong number of objects")
+			continue
+		}
+		for j := range objects {
+			o, o2 := &objects[j], &objects2[j]
+			if o.ID != o2.ID || o.Name != o2.Name || o.X != o2.X || o.Y != o2.Y || o.GID != o2.GID || o.Visible != o2.Visible || o.Shape() != o2.Shape() || len(o.Properties) != len(o2.Properties) {
+				t.Error(name, "object", o.Name, "differs")
+			}
+		}
+	}
+
+	if len(m2.Properties) != len(m.Properties) {
+		t.Error(name, "map properties differ")
+	}
+	for i := range m.Properties {
+		p, p2 := &m.Properties[i], &m2.Properties[i]
+		if p.Name != p2.Name || p.Type != p2.Type || p.Value != p2.Value || len(p.Properties) != len(p2.Properties) {
+			t.Error(name, "property", p.Name, "differs")
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	names, err := filepath.Glob("testdata/*.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range names {
+		m, err := ReadFile(name)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+
+		compareMaps(t, name, m, rewrite(t, m, name))
+	}
+}
+
+func TestWriteEncodings(t *testing.T) {
+	m, err := ReadFile("testdata/csv.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encodings := []struct{ encoding, compression string }{
+		{"csv", ""}, {"base64", ""}, {"base64", "zlib"}, {"base64", "gzip"}, {"base64", "zstd"}, {"", ""},
+	}
+
+	for _, enc := range encodings {
+		m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = enc.encoding, enc.compression
+
+		m2 := rewrite(t, m, "testdata/csv.tmx")
+		if m2.Layers[0].Data.Encoding != enc.encoding || m2.Layers[0].Data.Compression != enc.compression {
+			t.Error("Encoding not written", enc)
+		}
+
+		gids, err := m2.decodeLayer(&m2.Layers[0])
+		if err != nil {
+			t.Fatal(enc, err)
+		}
+		if !equalGIDs(gids, layer0Data) {
+			t.Error("Wrong data written with", enc)
+		}
+	}
+
+	m.Layers[0].Data.Encoding, m.Layers[0].Data.Compression = "base64", "nosuchcompression"
+	if err := m.Write(new(bytes.Buffer)); err != UnknownCompression {
+		t.Error("Wrong error for an unknown compression", err)
+	}
+}
+
+// Returns a map made in Go, with no layer tree.
+func madeInGo() *Map {
+	o := NewObject(1)
+	o.Name = "o"
+	return &Map{
+		Width: 2, Height: 1, TileWidth: 8, TileHeight: 8, RenderOrder: "right-down",
+		Tilesets:     []Tileset{{FirstGID: 1, Name: "ts", TileWidth: 8, TileHeight: 8, Tilecount: 4}},
+		Layers:       []Layer{{LayerBase: NewLayerBase("Ground"), Width: 2, Height: 1, GIDs: []GID{1, 2}, Data: Data{Encoding: "csv"}}},
+		ObjectGroups: []ObjectGroup{{LayerBase: NewLayerBase("Objects"), Objects: []Object{o}}},
+	}
+}
+
+func TestWriteFlatLayers(t *testing.T) {
+	m := madeInGo()
+	var buf bytes.Buffer
+	if err := m.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); strings.Contains(s, "visible") || strings.Contains(s, "opacity") || strings.Contains(s, "parallax") {
+		t.Error("Defaults written", s)
+	}
+	m2 := rewrite(t, m, "")
+	compareMaps(t, "made in Go", m, m2)
+	if len(m2.LayerTree) != 2 || m2.LayerTree[0].Layer == nil || m2.LayerTree[1].ObjectGroup == nil {
+		t.Error("Wrong layer tree written", m2.LayerTree)
+	}
+
+	// Layers appended to a map read from a file, which also moves the layers of the tree out of the slice.
+	m, err := ReadFile("testdata/group.tmx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Layers = append(m.Layers, Layer{LayerBase: NewLayerBase("Extra"), Width: 2, Height: 2, GIDs: []GID{1, 1, 1, 1}})
+	m.Layers[1].Name = "Mist"
+
+	m2 = rewrite(t, m, "testdata/group.tmx")
+	if len(m2.Layers) != 4 || m2.Layers[3].Name != "Extra" || m2.LayerTree[len(m2.LayerTree)-1].Layer != &m2.Layers[3] {
+		t.Error("Appended layer not written last")
+	}
+	if m2.LayerByPath("Background/Sky/Mist") == nil {
+		t.Error("Change to a layer of the slice not written")
+	}
+}